@@ -5,41 +5,124 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 // LookupOrder Get a customer’s in-app purchases from a receipt using the order ID.
+// 订单无效时返回 nil, nil，需要区分订单无效和没有交易时请使用 LookupOrderID
 // api: https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
 func (s *Service) LookupOrder(ctx context.Context, customerOrderID string) ([]Transaction, error) {
+	res, err := s.LookupOrderID(ctx, customerOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Status == OrderLookupStatusInvalid {
+		return nil, nil
+	}
+
+	return res.GetTransactions()
+}
+
+// LookupOrderResp A response that includes the order lookup status and an array of signed transactions for the in-app purchases in the order
+// https://developer.apple.com/documentation/appstoreserverapi/orderlookupresponse
+type LookupOrderResp struct {
+	Status             OrderLookupStatus `json:"status"`
+	SignedTransactions []JWSTransaction  `json:"signedTransactions"`
+
+	service *Service
+}
+
+// GetTransactions 获取订单中的交易信息
+func (resp *LookupOrderResp) GetTransactions() ([]Transaction, error) {
+	if resp.service == nil {
+		return JWSTransactions(resp.SignedTransactions).GetTransactions()
+	}
+	return resp.service.SignedDataVerifier().Transactions(resp.SignedTransactions)
+}
+
+// GroupByOriginalTransactionID 获取订单中的交易信息，并按 originalTransactionId 分组
+func (resp *LookupOrderResp) GroupByOriginalTransactionID() (map[string][]Transaction, error) {
+	transactions, err := resp.GetTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]Transaction)
+	for _, v := range transactions {
+		out[v.OriginalTransactionID] = append(out[v.OriginalTransactionID], v)
+	}
+	return out, nil
+}
+
+// LookupOrderID Get a customer’s in-app purchases from a receipt using the order ID.
+// https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+func (s *Service) LookupOrderID(ctx context.Context, customerOrderID string) (*LookupOrderResp, error) {
 	_, body, err := s.get(ctx, "/inApps/v1/lookup/"+customerOrderID, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	type LookupOrderResp struct {
-		// 0 contains an array of one or more signed transactions for the in-app purchase based on the order ID.
-		// 1 1 doesn't contain a signed transactions array.
-		Status             int              `json:"status"`
-		SignedTransactions []JWSTransaction `json:"signedTransactions"`
+	var out LookupOrderResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
 	}
 
-	var res LookupOrderResp
-	if err := json.Unmarshal(body, &res); err != nil {
+	out.service = s
+	return &out, nil
+}
+
+// OrderDetail 订单中的交易及订阅状态
+type OrderDetail struct {
+	Status OrderLookupStatus
+	// 按 originalTransactionId 分组的交易
+	Transactions map[string][]Transaction
+	// 自动续期订阅的当前状态，key 为 originalTransactionId
+	SubscriptionStatuses map[string]*GetAllSubscriptionStatusesResp
+}
+
+// LookupOrderDetail 查询订单中的交易，并查询其中每个自动续期订阅的当前状态，用于客服处理用户反馈
+func (s *Service) LookupOrderDetail(ctx context.Context, customerOrderID string) (*OrderDetail, error) {
+	res, err := s.LookupOrderID(ctx, customerOrderID)
+	if err != nil {
 		return nil, err
 	}
 
-	if 1 == res.Status {
-		return nil, nil
+	out := &OrderDetail{
+		Status:               res.Status,
+		SubscriptionStatuses: make(map[string]*GetAllSubscriptionStatusesResp),
+	}
+	if res.Status == OrderLookupStatusInvalid {
+		return out, nil
 	}
 
-	return JWSTransactions(res.SignedTransactions).GetTransactions()
+	if out.Transactions, err = res.GroupByOriginalTransactionID(); err != nil {
+		return nil, err
+	}
+
+	for originalTransactionID, transactions := range out.Transactions {
+		if transactions[0].Type != TransactionTypeAutoRenewableSubscription {
+			continue
+		}
+
+		status, err := s.GetAllSubscriptionStatuses(ctx, originalTransactionID, nil)
+		if err != nil {
+			return nil, err
+		}
+		out.SubscriptionStatuses[originalTransactionID] = status
+	}
+
+	return out, nil
 }
 
 // GetTransactionInfo Get information about a single transaction for your app
+// 开启 SandboxFallback 时，生产环境找不到交易会查询 sandbox 环境，Transaction.Environment 为实际返回的环境
 // https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
 func (s *Service) GetTransactionInfo(ctx context.Context, transactionID string) (*Transaction, error) {
-	_, body, err := s.get(ctx, "/inApps/v1/transactions/"+transactionID, nil)
+	used, body, err := s.getWithFallback(ctx, "/inApps/v1/transactions/"+transactionID, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +136,31 @@ func (s *Service) GetTransactionInfo(ctx context.Context, transactionID string)
 		return nil, err
 	}
 
-	return res.SignedTransactionInfo.GetTransaction()
+	// 使用实际返回数据的环境验证
+	return used.SignedDataVerifier().Transaction(res.SignedTransactionInfo)
+}
+
+// UpdateAppAccountTokenRequest The request body that contains an app account token value
+// https://developer.apple.com/documentation/appstoreserverapi/updateappaccounttokenrequest
+type UpdateAppAccountTokenRequest struct {
+	// [Required] The UUID that an app optionally generates to map a customer’s in-app purchase with its resulting App Store transaction
+	AppAccountToken string `json:"appAccountToken"`
+}
+
+// SetAppAccountToken Sets the app account token value for a purchase the customer makes outside your app, or updates its value in an existing transaction
+// appAccountToken 需为 UUID 格式，可用于将接入 appAccountToken 之前的交易关联到用户账号
+// https://developer.apple.com/documentation/appstoreserverapi/set-app-account-token
+func (s *Service) SetAppAccountToken(ctx context.Context, originalTransactionID string, appAccountToken string) error {
+	if originalTransactionID == "" {
+		return errors.New("appstore.appstoreserverapi.SetAppAccountToken: empty originalTransactionId")
+	}
+	if !isUUID(appAccountToken) {
+		return fmt.Errorf("appstore.appstoreserverapi.SetAppAccountToken: appAccountToken is not a UUID:%q", appAccountToken)
+	}
+
+	req := &UpdateAppAccountTokenRequest{AppAccountToken: appAccountToken}
+	_, _, err := s.requestJSON(ctx, "PUT", "/inApps/v1/transactions/"+originalTransactionID+"/appAccountToken", req)
+	return err
 }
 
 type GetTransactionHistoryReq struct {
@@ -163,7 +270,18 @@ type GetTransactionHistoryResp struct {
 
 // GetTransactions 获取当前返回数据中的交易信息
 func (resp *GetTransactionHistoryResp) GetTransactions() ([]Transaction, error) {
-	return JWSTransactions(resp.SignedTransactions).GetTransactions()
+	if resp.service == nil {
+		return JWSTransactions(resp.SignedTransactions).GetTransactions()
+	}
+	return resp.service.SignedDataVerifier().Transactions(resp.SignedTransactions)
+}
+
+// DecodeTransactions 并行解码当前返回数据中的交易信息，单个交易失败不影响其他交易，参见 SignedDataVerifier.DecodeTransactions
+func (resp *GetTransactionHistoryResp) DecodeTransactions(workers int) ([]*Transaction, error) {
+	if resp.service == nil {
+		return JWSTransactions(resp.SignedTransactions).DecodeTransactions(workers)
+	}
+	return resp.service.SignedDataVerifier().DecodeTransactions(resp.SignedTransactions, workers)
 }
 
 // Next GetTransactionHistory 的下一页
@@ -189,9 +307,10 @@ func (resp *GetTransactionHistoryResp) Next(ctx context.Context) (*GetTransactio
 }
 
 // GetTransactionHistory Get a customer’s in-app purchase transaction history for your app
+// 开启 SandboxFallback 时，生产环境找不到交易会查询 sandbox 环境，Environment 为实际返回的环境，Next 继续查询该环境
 // https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
 func (s *Service) GetTransactionHistory(ctx context.Context, req *GetTransactionHistoryReq) (*GetTransactionHistoryResp, error) {
-	_, body, err := s.get(ctx, "/inApps/v1/history/"+req.TransactionID, req.Query.Values())
+	used, body, err := s.getWithFallback(ctx, "/inApps/v1/history/"+req.TransactionID, req.Query.Values())
 	if err != nil {
 		return nil, err
 	}
@@ -201,8 +320,8 @@ func (s *Service) GetTransactionHistory(ctx context.Context, req *GetTransaction
 		return nil, err
 	}
 
-	// 调用 next 使用
-	out.service = s
+	// 调用 next 及验证交易使用，为实际返回数据的环境
+	out.service = used
 	out.req = req
 
 	return &out, nil
@@ -251,7 +370,18 @@ type GetRefundHistoryResp struct {
 
 // GetTransactions 获取当前返回数据中的交易信息
 func (resp *GetRefundHistoryResp) GetTransactions() ([]Transaction, error) {
-	return JWSTransactions(resp.SignedTransactions).GetTransactions()
+	if resp.service == nil {
+		return JWSTransactions(resp.SignedTransactions).GetTransactions()
+	}
+	return resp.service.SignedDataVerifier().Transactions(resp.SignedTransactions)
+}
+
+// DecodeTransactions 并行解码当前返回数据中的交易信息，单个交易失败不影响其他交易，参见 SignedDataVerifier.DecodeTransactions
+func (resp *GetRefundHistoryResp) DecodeTransactions(workers int) ([]*Transaction, error) {
+	if resp.service == nil {
+		return JWSTransactions(resp.SignedTransactions).DecodeTransactions(workers)
+	}
+	return resp.service.SignedDataVerifier().DecodeTransactions(resp.SignedTransactions, workers)
 }
 
 // Next GetTransactionHistoryResp 的下一页
@@ -263,14 +393,23 @@ func (resp *GetRefundHistoryResp) Next(ctx context.Context) (*GetRefundHistoryRe
 	return resp.service.GetRefundHistory(ctx, resp.transactionID, resp.Revision)
 }
 
+// Environment 返回数据的环境
+func (resp *GetRefundHistoryResp) Environment() Environment {
+	if resp.service == nil {
+		return ""
+	}
+	return resp.service.Environment()
+}
+
 // GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+// 开启 SandboxFallback 时，生产环境找不到交易会查询 sandbox 环境，参见 GetRefundHistoryResp.Environment
 func (s *Service) GetRefundHistory(ctx context.Context, transactionID string, revision string) (*GetRefundHistoryResp, error) {
 	query := url.Values{}
 	if revision != "" {
 		query.Add("revision", revision)
 	}
 
-	_, body, err := s.get(ctx, "/inApps/v2/refund/lookup/"+transactionID, query)
+	used, body, err := s.getWithFallback(ctx, "/inApps/v2/refund/lookup/"+transactionID, query)
 	if err != nil {
 		return nil, err
 	}
@@ -281,7 +420,8 @@ func (s *Service) GetRefundHistory(ctx context.Context, transactionID string, re
 	}
 
 	out.transactionID = transactionID
-	out.service = s
+	// 调用 next 及验证交易使用，为实际返回数据的环境
+	out.service = used
 
 	return &out, nil
 }
@@ -293,13 +433,13 @@ type GetNotificationHistoryReq struct {
 	// [Required]
 	EndDate int64 `json:"endDate"`
 	// [Optional]
-	NotificationType NotificationV2Type `json:"notificationType"`
+	NotificationType NotificationV2Type `json:"notificationType,omitempty"`
 	// [Optional]
-	NotificationSubtype NotificationV2Subtype `json:"notificationSubtype"`
+	NotificationSubtype NotificationV2Subtype `json:"notificationSubtype,omitempty"`
 	// [Optional]
-	OnlyFailures bool `json:"onlyFailures"`
+	OnlyFailures bool `json:"onlyFailures,omitempty"`
 	// [Optional]
-	TransactionId string `json:"transactionId"`
+	TransactionId string `json:"transactionId,omitempty"`
 }
 
 // GetNotificationHistoryResp A response that contains the App Store Server Notifications history for your app
@@ -316,7 +456,7 @@ type GetNotificationHistoryResp struct {
 
 // Next GetNotificationHistoryResp 下一页数据
 func (resp *GetNotificationHistoryResp) Next(ctx context.Context) (*GetNotificationHistoryResp, error) {
-	if resp.HasMore {
+	if !resp.HasMore {
 		return nil, nil
 	}
 
@@ -392,3 +532,402 @@ func (s *Service) GetTestNotificationStatus(ctx context.Context, testNotificatio
 
 	return &out, nil
 }
+
+// ConsumptionRequest The request body containing consumption information
+// https://developer.apple.com/documentation/appstoreserverapi/consumptionrequest
+type ConsumptionRequest struct {
+	// [Required] The age of the customer’s account
+	AccountTenure AccountTenure `json:"accountTenure"`
+	// [Required] The UUID that an app optionally generates to map a customer’s in-app purchase with its resulting App Store transaction.
+	// Use an empty string if you don't have an appAccountToken
+	AppAccountToken string `json:"appAccountToken"`
+	// [Required] A value that indicates the extent to which the customer consumed the in-app purchase
+	ConsumptionStatus ConsumptionStatus `json:"consumptionStatus"`
+	// [Required] A Boolean value that indicates whether the customer consented to provide consumption data to the App Store.
+	// Must be true
+	CustomerConsented bool `json:"customerConsented"`
+	// [Required] A value that indicates whether the app successfully delivered an in-app purchase that works properly
+	DeliveryStatus DeliveryStatus `json:"deliveryStatus"`
+	// [Required] A value that indicates the total amount, in USD, of in-app purchases the customer has made in your app, across all platforms
+	LifetimeDollarsPurchased LifetimeDollars `json:"lifetimeDollarsPurchased"`
+	// [Required] A value that indicates the total amount, in USD, of refunds the customer has received, in your app, across all platforms
+	LifetimeDollarsRefunded LifetimeDollars `json:"lifetimeDollarsRefunded"`
+	// [Required] A value that indicates the platform on which the customer consumed the in-app purchase
+	Platform Platform `json:"platform"`
+	// [Required] A value that indicates the amount of time that the customer used the app
+	PlayTime PlayTime `json:"playTime"`
+	// [Optional] A value that indicates your preference, based on your operational logic, as to whether Apple should grant the refund
+	RefundPreference RefundPreference `json:"refundPreference,omitempty"`
+	// [Required] A Boolean value that indicates whether you provided, prior to its purchase, a free sample or trial of the content,
+	// or information about its functionality
+	SampleContentProvided bool `json:"sampleContentProvided"`
+	// [Required] The status of the customer’s account
+	UserStatus UserStatus `json:"userStatus"`
+}
+
+// Validate 检查必填字段及枚举取值范围
+func (r *ConsumptionRequest) Validate() error {
+	switch {
+	case !r.CustomerConsented:
+		return errors.New("appstore.appstoreserverapi.ConsumptionRequest: customerConsented must be true")
+	case r.AppAccountToken != "" && !isUUID(r.AppAccountToken):
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid appAccountToken:%q", r.AppAccountToken)
+	case r.AccountTenure < 0 || r.AccountTenure > AccountTenureGreaterThanOneYear:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid accountTenure:%d", r.AccountTenure)
+	case r.ConsumptionStatus < 0 || r.ConsumptionStatus > ConsumptionStatusFullyConsumed:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid consumptionStatus:%d", r.ConsumptionStatus)
+	case r.DeliveryStatus < 0 || r.DeliveryStatus > DeliveryStatusDidNotDeliverOtherReason:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid deliveryStatus:%d", r.DeliveryStatus)
+	case r.LifetimeDollarsPurchased < 0 || r.LifetimeDollarsPurchased > LifetimeDollarsTwoThousandOrGreater:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid lifetimeDollarsPurchased:%d", r.LifetimeDollarsPurchased)
+	case r.LifetimeDollarsRefunded < 0 || r.LifetimeDollarsRefunded > LifetimeDollarsTwoThousandOrGreater:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid lifetimeDollarsRefunded:%d", r.LifetimeDollarsRefunded)
+	case r.Platform < 0 || r.Platform > PlatformNonApple:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid platform:%d", r.Platform)
+	case r.PlayTime < 0 || r.PlayTime > PlayTimeOverSixteenDays:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid playTime:%d", r.PlayTime)
+	case r.RefundPreference < 0 || r.RefundPreference > RefundPreferenceNoPreference:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid refundPreference:%d", r.RefundPreference)
+	case r.UserStatus < 0 || r.UserStatus > UserStatusLimitedAccess:
+		return fmt.Errorf("appstore.appstoreserverapi.ConsumptionRequest: invalid userStatus:%d", r.UserStatus)
+	}
+
+	return nil
+}
+
+// SendConsumptionInformation Send consumption information about a consumable in-app purchase to the App Store
+// after your server receives a consumption request notification
+// https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
+func (s *Service) SendConsumptionInformation(ctx context.Context, transactionID string, req *ConsumptionRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	_, _, err := s.requestJSON(ctx, "PUT", "/inApps/v1/transactions/consumption/"+transactionID, req)
+	return err
+}
+
+// ConsumptionWindow 收到 CONSUMPTION_REQUEST 通知后，需在 12 小时内发送消费信息
+const ConsumptionWindow = 12 * time.Hour
+
+// ErrConsumptionWindowExpired 已超过 CONSUMPTION_REQUEST 通知的响应时间
+var ErrConsumptionWindowExpired = errors.New("appstore.appstoreserverapi: consumption request window expired")
+
+// ConsumptionProvider 根据退款请求的交易，提供发送给 App Store 的消费信息
+type ConsumptionProvider interface {
+	ConsumptionInfo(ctx context.Context, notification *NotificationV2, transaction *Transaction) (*ConsumptionRequest, error)
+}
+
+// ConsumptionProviderFunc 函数形式的 ConsumptionProvider
+type ConsumptionProviderFunc func(ctx context.Context, notification *NotificationV2, transaction *Transaction) (*ConsumptionRequest, error)
+
+func (f ConsumptionProviderFunc) ConsumptionInfo(ctx context.Context, notification *NotificationV2, transaction *Transaction) (*ConsumptionRequest, error) {
+	return f(ctx, notification, transaction)
+}
+
+// RespondConsumptionRequest 处理 CONSUMPTION_REQUEST 通知：验证通知中的交易，从 provider 获取消费信息并发送给 App Store
+// 超过通知 signedDate 后 12 小时返回 ErrConsumptionWindowExpired，ctx 的截止时间不会晚于该时间
+// https://developer.apple.com/documentation/appstoreservernotifications/notificationtype
+func (s *Service) RespondConsumptionRequest(ctx context.Context, notification *NotificationV2, provider ConsumptionProvider) error {
+	if notification.NotificationType != NotificationV2TypeConsumptionRequest {
+		return fmt.Errorf("appstore.appstoreserverapi: unexpected notification type:%s", notification.NotificationType)
+	}
+
+	deadline := time.UnixMilli(notification.SignedDate).Add(ConsumptionWindow)
+	if !time.Now().Before(deadline) {
+		return ErrConsumptionWindowExpired
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	transaction, err := s.SignedDataVerifier().Transaction(notification.Data.SignedTransactionInfo)
+	if err != nil {
+		return err
+	}
+
+	req, err := provider.ConsumptionInfo(ctx, notification, transaction)
+	if err != nil {
+		return err
+	}
+
+	return s.SendConsumptionInformation(ctx, transaction.TransactionID, req)
+}
+
+const (
+	// MaxExtendByDays 单次延长订阅续订日期的最大天数
+	MaxExtendByDays = 90
+	// MaxExtensionsPerYear 365 天内同一订阅最多延长的次数
+	MaxExtensionsPerYear = 2
+)
+
+// ErrExtensionLimitExceeded 365 天内已延长 MaxExtensionsPerYear 次
+var ErrExtensionLimitExceeded = errors.New("appstore.appstoreserverapi: subscription renewal date extended twice in the past 365 days")
+
+// CheckExtensionLimit 根据已延长的时间 previous（由调用方记录）检查 at 时是否还能延长订阅续订日期
+// App Store 不提供查询历史延长记录的接口，超过限制时 ExtendSubscriptionRenewalDate 返回 ErrCodeSubscriptionMaxExtension
+func CheckExtensionLimit(previous []time.Time, at time.Time) error {
+	count := 0
+	for _, t := range previous {
+		if t.After(at.AddDate(0, 0, -365)) && !t.After(at) {
+			count++
+		}
+	}
+
+	if count >= MaxExtensionsPerYear {
+		return ErrExtensionLimitExceeded
+	}
+	return nil
+}
+
+// ExtendRenewalDateRequest The request body that contains subscription-renewal-extension data for an individual subscription
+// https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldaterequest
+type ExtendRenewalDateRequest struct {
+	// [Required] The number of days to extend the subscription renewal date. The maximum is 90
+	ExtendByDays int32 `json:"extendByDays"`
+	// [Required] The reason code for the subscription date extension
+	ExtendReasonCode ExtendReasonCode `json:"extendReasonCode"`
+	// [Required] A string that contains a unique identifier you provide to track each subscription-renewal-date extension request.
+	// The maximum length is 128 characters
+	RequestIdentifier string `json:"requestIdentifier"`
+}
+
+// Validate 检查延长天数、原因及 requestIdentifier
+func (r *ExtendRenewalDateRequest) Validate() error {
+	switch {
+	case r.ExtendByDays < 1 || r.ExtendByDays > MaxExtendByDays:
+		return fmt.Errorf("appstore.appstoreserverapi.ExtendRenewalDateRequest: extendByDays must be between 1 and %d, got:%d", MaxExtendByDays, r.ExtendByDays)
+	case r.ExtendReasonCode < 0 || r.ExtendReasonCode > ExtendReasonCodeServiceIssueOrOutage:
+		return fmt.Errorf("appstore.appstoreserverapi.ExtendRenewalDateRequest: invalid extendReasonCode:%d", r.ExtendReasonCode)
+	case r.RequestIdentifier == "" || len(r.RequestIdentifier) > 128:
+		return errors.New("appstore.appstoreserverapi.ExtendRenewalDateRequest: requestIdentifier must be 1 to 128 characters")
+	}
+
+	return nil
+}
+
+// ExtendRenewalDateResp A response that indicates whether an individual renewal-date extension succeeded, and related details
+// https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldateresponse
+type ExtendRenewalDateResp struct {
+	// The new subscription expiration date for a subscription-renewal extension, UNIX time in milliseconds
+	EffectiveDate         int64  `json:"effectiveDate"`
+	OriginalTransactionID string `json:"originalTransactionId"`
+	// A Boolean value that indicates whether the subscription-renewal-date extension succeeded
+	Success            bool   `json:"success"`
+	WebOrderLineItemID string `json:"webOrderLineItemId"`
+}
+
+// ExtensionHistoryFunc 返回订阅 originalTransactionID 已延长续订日期的时间，由调用方记录
+type ExtensionHistoryFunc func(ctx context.Context, originalTransactionID string) ([]time.Time, error)
+
+// ExtensionHistory 设置 ExtendSubscriptionRenewalDate 检查 365 天内延长次数使用的历史记录
+func (s *Service) ExtensionHistory(fn ExtensionHistoryFunc) *Service {
+	ns := s.clone()
+	ns.extensionHistory = fn
+	return ns
+}
+
+// ExtendSubscriptionRenewalDate Extends the renewal date of a customer’s active subscription using the original transaction identifier
+// 请求前检查 ExtendByDays 不超过 MaxExtendByDays；
+// 设置了 ExtensionHistory 时，365 天内已延长 MaxExtensionsPerYear 次返回 ErrExtensionLimitExceeded，
+// 否则不检查延长次数，由 App Store 返回 ErrCodeSubscriptionMaxExtension
+// https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (s *Service) ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionID string, req *ExtendRenewalDateRequest) (*ExtendRenewalDateResp, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if s.extensionHistory != nil {
+		previous, err := s.extensionHistory(ctx, originalTransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckExtensionLimit(previous, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	_, body, err := s.requestJSON(ctx, "PUT", "/inApps/v1/subscriptions/extend/"+originalTransactionID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out ExtendRenewalDateResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// MassExtendRenewalDateRequest The request body that contains subscription-renewal-extension data to apply for all eligible active subscribers
+// https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldaterequest
+type MassExtendRenewalDateRequest struct {
+	// [Required] The number of days to extend the subscription renewal date. The maximum is 90
+	ExtendByDays int32 `json:"extendByDays"`
+	// [Required] The reason code for the subscription-renewal-date extension
+	ExtendReasonCode ExtendReasonCode `json:"extendReasonCode"`
+	// [Required] The product identifier of the auto-renewable subscription that you’re requesting the renewal-date extension for
+	ProductID string `json:"productId"`
+	// [Required] A UUID that uniquely identifies the mass extension request. 为空时自动生成
+	RequestIdentifier string `json:"requestIdentifier"`
+	// [Optional] A list of storefront country codes you provide to limit the storefronts for a subscription-renewal-date extension
+	StorefrontCountryCodes []string `json:"storefrontCountryCodes,omitempty"`
+}
+
+// Validate 检查延长天数、原因、productId 及 requestIdentifier
+func (r *MassExtendRenewalDateRequest) Validate() error {
+	switch {
+	case r.ExtendByDays < 1 || r.ExtendByDays > MaxExtendByDays:
+		return fmt.Errorf("appstore.appstoreserverapi.MassExtendRenewalDateRequest: extendByDays must be between 1 and %d, got:%d", MaxExtendByDays, r.ExtendByDays)
+	case r.ExtendReasonCode < 0 || r.ExtendReasonCode > ExtendReasonCodeServiceIssueOrOutage:
+		return fmt.Errorf("appstore.appstoreserverapi.MassExtendRenewalDateRequest: invalid extendReasonCode:%d", r.ExtendReasonCode)
+	case r.ProductID == "":
+		return errors.New("appstore.appstoreserverapi.MassExtendRenewalDateRequest: empty productId")
+	case !isUUID(r.RequestIdentifier):
+		return fmt.Errorf("appstore.appstoreserverapi.MassExtendRenewalDateRequest: requestIdentifier must be a UUID, got:%q", r.RequestIdentifier)
+	}
+
+	return nil
+}
+
+type MassExtendRenewalDateResp struct {
+	RequestIdentifier string `json:"requestIdentifier"`
+}
+
+// ExtendRenewalDateForAllActiveSubscribers Uses a subscription’s product identifier to extend the renewal date for all of its eligible active subscribers
+// https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
+func (s *Service) ExtendRenewalDateForAllActiveSubscribers(ctx context.Context, req *MassExtendRenewalDateRequest) (*MassExtendRenewalDateResp, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	_, body, err := s.requestJSON(ctx, "POST", "/inApps/v1/subscriptions/extend/mass", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out MassExtendRenewalDateResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// MassExtendRenewalDateStatusResp A response that indicates the current status of a request to extend the subscription renewal date to all eligible subscribers
+// https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldatestatusresponse
+type MassExtendRenewalDateStatusResp struct {
+	RequestIdentifier string `json:"requestIdentifier"`
+	// A Boolean value that indicates whether the App Store completed the request to extend a subscription renewal date to active subscribers
+	Complete bool `json:"complete"`
+	// The UNIX time, in milliseconds, that the App Store completes a request to extend a subscription renewal date for eligible subscribers
+	CompleteDate int64 `json:"completeDate"`
+	// The count of subscriptions that successfully receive a subscription-renewal-date extension
+	SucceededCount int64 `json:"succeededCount"`
+	// The count of subscriptions that fail to receive a subscription-renewal-date extension
+	FailedCount int64 `json:"failedCount"`
+}
+
+// GetStatusOfSubscriptionRenewalDateExtensions Checks whether a renewal date extension request completed, and provides the final count of successful or failed extensions
+// https://developer.apple.com/documentation/appstoreserverapi/get_status_of_subscription_renewal_date_extensions
+func (s *Service) GetStatusOfSubscriptionRenewalDateExtensions(ctx context.Context, productID string, requestIdentifier string) (*MassExtendRenewalDateStatusResp, error) {
+	_, body, err := s.get(ctx, "/inApps/v1/subscriptions/extend/mass/"+productID+"/"+requestIdentifier, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out MassExtendRenewalDateStatusResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// MassExtensionJob 为所有有效订阅者延长续订日期的任务
+// App Store 处理完成后还会发送 RENEWAL_EXTENSION 类型、SUMMARY 子类型的通知，参见 MassExtensionStatusFromSummary
+type MassExtensionJob struct {
+	service *Service
+
+	ProductID         string
+	RequestIdentifier string
+
+	// PollInterval Wait 首次查询状态前的等待时间，之后每次查询的间隔加倍，默认 30s
+	PollInterval time.Duration
+	// MaxPollInterval Wait 查询状态间隔的上限，间隔加倍后不超过该值，默认 10m
+	MaxPollInterval time.Duration
+}
+
+// StartMassExtension 发起为所有有效订阅者延长续订日期的请求，req.RequestIdentifier 为空时自动生成 UUID，
+// 生成的 UUID 参见返回的 MassExtensionJob.RequestIdentifier，不修改 req
+func (s *Service) StartMassExtension(ctx context.Context, req *MassExtendRenewalDateRequest) (*MassExtensionJob, error) {
+	r := *req
+	req = &r
+	if req.RequestIdentifier == "" {
+		id, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		req.RequestIdentifier = id
+	}
+
+	if _, err := s.ExtendRenewalDateForAllActiveSubscribers(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return &MassExtensionJob{
+		service:           s,
+		ProductID:         req.ProductID,
+		RequestIdentifier: req.RequestIdentifier,
+		PollInterval:      30 * time.Second,
+		MaxPollInterval:   10 * time.Minute,
+	}, nil
+}
+
+// Status 查询任务当前状态
+func (j *MassExtensionJob) Status(ctx context.Context) (*MassExtendRenewalDateStatusResp, error) {
+	return j.service.GetStatusOfSubscriptionRenewalDateExtensions(ctx, j.ProductID, j.RequestIdentifier)
+}
+
+// Wait 按指数退避查询任务状态，直到任务完成或 ctx 结束
+func (j *MassExtensionJob) Wait(ctx context.Context) (*MassExtendRenewalDateStatusResp, error) {
+	interval := j.PollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		status, err := j.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Complete {
+			return status, nil
+		}
+
+		interval *= 2
+		if j.MaxPollInterval > 0 && interval > j.MaxPollInterval {
+			interval = j.MaxPollInterval
+		}
+	}
+}
+
+// MassExtensionStatusFromSummary 将任务完成时 App Store 发送的通知 summary 转换为任务状态
+func MassExtensionStatusFromSummary(summary *NotificationV2Summary) *MassExtendRenewalDateStatusResp {
+	return &MassExtendRenewalDateStatusResp{
+		RequestIdentifier: summary.RequestIdentifier,
+		Complete:          true,
+		SucceededCount:    summary.SucceededCount,
+		FailedCount:       summary.FailedCount,
+	}
+}
@@ -0,0 +1,37 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestService_SetAppAccountToken(t *testing.T) {
+	var gotReq UpdateAppAccountTokenRequest
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/inApps/v1/transactions/1000/appAccountToken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotReq)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	token := "7389a31a-fb6d-4569-a2a6-db7d85d84813"
+	if err := service.SetAppAccountToken(context.Background(), "1000", token); err != nil {
+		t.Errorf("TestService_SetAppAccountToken failed. err:%v", err)
+		return
+	}
+
+	if gotReq.AppAccountToken != token {
+		t.Errorf("TestService_SetAppAccountToken got req:%#v", gotReq)
+	}
+
+	if err := service.SetAppAccountToken(context.Background(), "1000", "not-a-uuid"); err == nil {
+		t.Errorf("TestService_SetAppAccountToken: invalid appAccountToken should be rejected")
+	}
+}
@@ -15,6 +15,8 @@ type Config struct {
 	KeyID string
 	// Your private key content
 	PrivateKey []byte
+	// [Optional] Your app’s Apple ID, used to verify the appAppleId of notifications
+	AppAppleID int64
 
 	// http request timeout
 	Timeout time.Duration
@@ -0,0 +1,61 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestService_SendConsumptionInformation(t *testing.T) {
+	signer, verifier := testSigner(t)
+
+	var (
+		gotPath string
+		gotReq  ConsumptionRequest
+	)
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.Method + " " + r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotReq)
+		w.WriteHeader(http.StatusAccepted)
+	}).Verifier(verifier)
+
+	tx := Transaction{TransactionID: "2000", BundleID: "com.example", Environment: EnvironmentProduction}
+	notification := &NotificationV2{
+		NotificationType: NotificationV2TypeConsumptionRequest,
+		SignedDate:       time.Now().Add(-time.Hour).UnixMilli(),
+		Data:             NotificationV2Data{SignedTransactionInfo: JWSTransaction(testSign(t, signer, tx))},
+	}
+
+	provider := ConsumptionProviderFunc(func(ctx context.Context, n *NotificationV2, transaction *Transaction) (*ConsumptionRequest, error) {
+		return &ConsumptionRequest{
+			CustomerConsented: true,
+			ConsumptionStatus: ConsumptionStatusPartiallyConsumed,
+			PlayTime:          PlayTimeOneToSixHours,
+			RefundPreference:  RefundPreferencePreferDecline,
+		}, nil
+	})
+
+	if err := service.RespondConsumptionRequest(context.Background(), notification, provider); err != nil {
+		t.Errorf("TestService_SendConsumptionInformation failed. err:%v", err)
+		return
+	}
+
+	if gotPath != "PUT /inApps/v1/transactions/consumption/2000" || gotReq.ConsumptionStatus != ConsumptionStatusPartiallyConsumed || gotReq.PlayTime != PlayTimeOneToSixHours {
+		t.Errorf("TestService_SendConsumptionInformation got path:%s, req:%#v", gotPath, gotReq)
+	}
+
+	// 超过 12 小时
+	notification.SignedDate = time.Now().Add(-13 * time.Hour).UnixMilli()
+	if err := service.RespondConsumptionRequest(context.Background(), notification, provider); !errors.Is(err, ErrConsumptionWindowExpired) {
+		t.Errorf("TestService_SendConsumptionInformation expired got err:%v", err)
+	}
+
+	if err := service.SendConsumptionInformation(context.Background(), "2000", &ConsumptionRequest{}); err == nil {
+		t.Errorf("TestService_SendConsumptionInformation: customerConsented false should be rejected")
+	}
+}
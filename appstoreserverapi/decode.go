@@ -0,0 +1,84 @@
+package appstoreserverapi
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// TransactionError 解码第 Index 个交易失败
+type TransactionError struct {
+	Index int
+	Err   error
+}
+
+func (e *TransactionError) Error() string {
+	return fmt.Sprintf("index:%d, err:%v", e.Index, e.Err)
+}
+
+func (e *TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// TransactionErrors 批量解码交易时的失败列表，按 Index 升序
+type TransactionErrors []*TransactionError
+
+func (es TransactionErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+	return fmt.Sprintf("appstore.appstoreserverapi: decode %d transactions failed: [%s]", len(es), strings.Join(msgs, "; "))
+}
+
+// DecodeTransactions 使用最多 workers 个 goroutine 并行验证并解码交易，workers <= 0 时使用 GOMAXPROCS
+// 返回与 ts 序号一一对应的交易，解码失败的位置为 nil，单个交易失败不影响其他交易；
+// 有交易解码失败时返回 TransactionErrors，包含失败的序号和原因，全部成功时返回 nil
+func (v *SignedDataVerifier) DecodeTransactions(ts JWSTransactions, workers int) ([]*Transaction, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(ts) {
+		workers = len(ts)
+	}
+
+	results := make([]*Transaction, len(ts))
+	errs := make([]error, len(ts))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = v.Transaction(ts[i])
+			}
+		}()
+	}
+
+	for i := range ts {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed TransactionErrors
+	for i := range ts {
+		if errs[i] != nil {
+			results[i] = nil
+			failed = append(failed, &TransactionError{Index: i, Err: errs[i]})
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, failed
+	}
+	return results, nil
+}
+
+// DecodeTransactions 使用 jws.DefaultVerifier 并行验证并解码交易，参见 SignedDataVerifier.DecodeTransactions
+func (ts JWSTransactions) DecodeTransactions(workers int) ([]*Transaction, error) {
+	return NewSignedDataVerifier("", 0, "", nil).DecodeTransactions(ts, workers)
+}
@@ -0,0 +1,59 @@
+package appstoreserverapi
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSignedDataVerifier_DecodeTransactions(t *testing.T) {
+	signer, verifier := testSigner(t)
+	v := NewSignedDataVerifier("com.example", 0, EnvironmentProduction, verifier)
+
+	ts := make(JWSTransactions, 0, 20)
+	for i := 0; i < 20; i++ {
+		tx := Transaction{TransactionID: strconv.Itoa(i), BundleID: "com.example", Environment: EnvironmentProduction}
+		ts = append(ts, JWSTransaction(testSign(t, signer, tx)))
+	}
+	ts[7] = "malformed"
+	ts[12] = JWSTransaction(testSign(t, signer, Transaction{TransactionID: "12", BundleID: "com.other", Environment: EnvironmentProduction}))
+
+	got, err := v.DecodeTransactions(ts, 4)
+	var errs TransactionErrors
+	if len(got) != 20 || !errors.As(err, &errs) || len(errs) != 2 {
+		t.Errorf("TestSignedDataVerifier_DecodeTransactions got transactions:%d, err:%v", len(got), err)
+		return
+	}
+
+	if errs[0].Index != 7 || errs[1].Index != 12 {
+		t.Errorf("TestSignedDataVerifier_DecodeTransactions got error indexes:%d,%d", errs[0].Index, errs[1].Index)
+	}
+
+	var verr *VerificationError
+	if !errors.As(errs[1], &verr) {
+		t.Errorf("TestSignedDataVerifier_DecodeTransactions errs[1] should be VerificationError, got:%v", errs[1])
+	}
+
+	// 与 ts 序号一一对应，失败的位置为 nil
+	for i := range got {
+		if i == 7 || i == 12 {
+			if got[i] != nil {
+				t.Errorf("TestSignedDataVerifier_DecodeTransactions got[%d] should be nil, got:%#v", i, got[i])
+			}
+			continue
+		}
+		if got[i] == nil || got[i].TransactionID != strconv.Itoa(i) {
+			t.Errorf("TestSignedDataVerifier_DecodeTransactions got[%d]:%#v", i, got[i])
+		}
+	}
+
+	// 全部成功时 error 为 nil 接口
+	got, err = v.DecodeTransactions(ts[:5], 0)
+	if len(got) != 5 || err != nil {
+		t.Errorf("TestSignedDataVerifier_DecodeTransactions got:%v, err:%v", got, err)
+	}
+
+	if got, err := v.DecodeTransactions(nil, 0); len(got) != 0 || err != nil {
+		t.Errorf("TestSignedDataVerifier_DecodeTransactions empty got:%v, err:%v", got, err)
+	}
+}
@@ -6,6 +6,33 @@ import (
 	"fmt"
 )
 
+// 部分 ApiError.Code
+// https://developer.apple.com/documentation/appstoreserverapi/error_codes
+const (
+	// An error that indicates the app account token value is not a valid UUID
+	ErrCodeInvalidAppAccountTokenUUID = 4000183
+	// An error that indicates the transaction is for a product the customer obtains through Family Sharing, which the endpoint doesn’t support
+	ErrCodeFamilyTransactionNotSupported = 4000185
+	// An error that indicates the transaction identifier doesn’t represent an original transaction
+	ErrCodeTransactionIDNotOriginalTransaction = 4000187
+	// An error that indicates the App Store account wasn’t found, but you can try again
+	ErrCodeAccountNotFoundRetryable = 4040002
+	// An error that indicates the app wasn’t found, but you can try again
+	ErrCodeAppNotFoundRetryable = 4040004
+	// An error that indicates the original transaction identifier wasn’t found, but you can try again
+	ErrCodeOriginalTransactionIDNotFoundRetryable = 4040006
+	// An error that indicates a transaction identifier wasn’t found
+	ErrCodeTransactionIDNotFound = 4040010
+	// An error that indicates the request exceeded the rate limit
+	ErrCodeRateLimitExceeded = 4290000
+	// An error that indicates a general internal error, but you can try again
+	ErrCodeGeneralInternalRetryable = 5000001
+	// An error that indicates the subscription doesn't qualify for a renewal-date extension due to its subscription state
+	ErrCodeSubscriptionExtensionIneligible = 4030004
+	// An error that indicates the subscription doesn’t qualify for a renewal-date extension because it has already received the maximum extensions
+	ErrCodeSubscriptionMaxExtension = 4030005
+)
+
 // ApiError https://developer.apple.com/documentation/appstoreserverapi/error_codes
 type ApiError struct {
 	Code    int    `json:"errorCode"`
@@ -30,6 +57,12 @@ func ApiErrorFromError(err error) (*ApiError, bool) {
 		return nil, true
 	}
 
+	// handleApiErr 返回 *ApiError
+	var ptr *ApiError
+	if errors.As(err, &ptr) {
+		return ptr, true
+	}
+
 	var apiErr ApiError
 	if errors.As(err, &apiErr) {
 		return &apiErr, true
@@ -39,7 +72,7 @@ func ApiErrorFromError(err error) (*ApiError, bool) {
 }
 
 func handleApiErr(statusCode int, payload []byte) error {
-	if statusCode != 200 {
+	if statusCode < 200 || statusCode >= 300 {
 		apiErr, err := ParseApiError(payload)
 		if err != nil {
 			return err
@@ -0,0 +1,117 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestService_ExtendSubscriptionRenewalDate(t *testing.T) {
+	var gotReq ExtendRenewalDateRequest
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/inApps/v1/subscriptions/extend/1000" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotReq)
+		w.Write([]byte(`{"effectiveDate":1698148900000,"originalTransactionId":"1000","success":true,"webOrderLineItemId":"1"}`))
+	})
+
+	req := &ExtendRenewalDateRequest{ExtendByDays: 7, ExtendReasonCode: ExtendReasonCodeServiceIssueOrOutage, RequestIdentifier: "outage-2024-01"}
+	got, err := service.ExtendSubscriptionRenewalDate(context.Background(), "1000", req)
+	if err != nil {
+		t.Errorf("TestService_ExtendSubscriptionRenewalDate failed. err:%v", err)
+		return
+	}
+
+	if !got.Success || got.EffectiveDate != 1698148900000 || gotReq != *req {
+		t.Errorf("TestService_ExtendSubscriptionRenewalDate got:%#v, req:%#v", got, gotReq)
+	}
+
+	req.ExtendByDays = 91
+	if _, err := service.ExtendSubscriptionRenewalDate(context.Background(), "1000", req); err == nil {
+		t.Errorf("TestService_ExtendSubscriptionRenewalDate: extendByDays > 90 should be rejected")
+	}
+
+	// 365 天内已延长 2 次
+	req.ExtendByDays = 7
+	now := time.Now()
+	limited := service.ExtensionHistory(func(ctx context.Context, originalTransactionID string) ([]time.Time, error) {
+		return []time.Time{now.AddDate(0, 0, -100), now.AddDate(0, 0, -10)}, nil
+	})
+	if _, err := limited.ExtendSubscriptionRenewalDate(context.Background(), "1000", req); !errors.Is(err, ErrExtensionLimitExceeded) {
+		t.Errorf("TestService_ExtendSubscriptionRenewalDate got err:%v, want ErrExtensionLimitExceeded", err)
+	}
+}
+
+func TestCheckExtensionLimit(t *testing.T) {
+	now := time.Now()
+	if err := CheckExtensionLimit([]time.Time{now.AddDate(0, 0, -400), now.AddDate(0, 0, -10)}, now); err != nil {
+		t.Errorf("TestCheckExtensionLimit got err:%v", err)
+	}
+
+	if err := CheckExtensionLimit([]time.Time{now.AddDate(0, 0, -300), now.AddDate(0, 0, -10)}, now); !errors.Is(err, ErrExtensionLimitExceeded) {
+		t.Errorf("TestCheckExtensionLimit got err:%v, want ErrExtensionLimitExceeded", err)
+	}
+}
+
+func TestService_StartMassExtension(t *testing.T) {
+	var (
+		gotReq MassExtendRenewalDateRequest
+		polls  int
+	)
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/inApps/v1/subscriptions/extend/mass":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &gotReq)
+			json.NewEncoder(w).Encode(MassExtendRenewalDateResp{RequestIdentifier: gotReq.RequestIdentifier})
+		case r.Method == "GET" && r.URL.Path == "/inApps/v1/subscriptions/extend/mass/com.example.monthly/"+gotReq.RequestIdentifier:
+			polls++
+			json.NewEncoder(w).Encode(MassExtendRenewalDateStatusResp{
+				RequestIdentifier: gotReq.RequestIdentifier,
+				Complete:          polls == 3,
+				SucceededCount:    10,
+				FailedCount:       1,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	req := &MassExtendRenewalDateRequest{
+		ExtendByDays:     3,
+		ExtendReasonCode: ExtendReasonCodeServiceIssueOrOutage,
+		ProductID:        "com.example.monthly",
+	}
+	job, err := service.StartMassExtension(context.Background(), req)
+	if err != nil {
+		t.Errorf("TestService_StartMassExtension failed. err:%v", err)
+		return
+	}
+
+	if !isUUID(job.RequestIdentifier) || job.RequestIdentifier != gotReq.RequestIdentifier {
+		t.Errorf("TestService_StartMassExtension got requestIdentifier:%s, sent:%s", job.RequestIdentifier, gotReq.RequestIdentifier)
+	}
+
+	if req.RequestIdentifier != "" {
+		t.Errorf("TestService_StartMassExtension: req should not be modified, got requestIdentifier:%s", req.RequestIdentifier)
+	}
+
+	job.PollInterval, job.MaxPollInterval = time.Millisecond, 2*time.Millisecond
+	status, err := job.Wait(context.Background())
+	if err != nil {
+		t.Errorf("TestService_StartMassExtension Wait failed. err:%v", err)
+		return
+	}
+
+	if polls != 3 || !status.Complete || status.SucceededCount != 10 || status.FailedCount != 1 {
+		t.Errorf("TestService_StartMassExtension got polls:%d, status:%#v", polls, status)
+	}
+}
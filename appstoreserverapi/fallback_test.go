@@ -0,0 +1,72 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestService_SandboxFallback(t *testing.T) {
+	signer, verifier := testSigner(t)
+	tx := testSign(t, signer, Transaction{TransactionID: "1000", BundleID: "com.example", Environment: EnvironmentSandbox})
+
+	var hosts []string
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		if r.Host == "api.storekit.itunes.apple.com" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/inApps/v1/transactions/1000":
+			json.NewEncoder(w).Encode(map[string]string{"signedTransactionInfo": tx})
+		case "/inApps/v2/refund/lookup/1000":
+			json.NewEncoder(w).Encode(map[string]interface{}{"signedTransactions": []string{tx}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Verifier(verifier)
+
+	// 默认不查询 sandbox 环境
+	_, err := service.GetTransactionInfo(context.Background(), "1000")
+	if apiErr, ok := ApiErrorFromError(err); !ok || apiErr == nil || apiErr.Code != ErrCodeTransactionIDNotFound {
+		t.Errorf("TestService_SandboxFallback got err:%v, want TransactionIdNotFound", err)
+		return
+	}
+	if len(hosts) != 1 {
+		t.Errorf("TestService_SandboxFallback got hosts:%v", hosts)
+		return
+	}
+
+	hosts = nil
+	service = service.SandboxFallback(true)
+	got, err := service.GetTransactionInfo(context.Background(), "1000")
+	if err != nil {
+		t.Errorf("TestService_SandboxFallback failed. err:%v", err)
+		return
+	}
+	if got.Environment != EnvironmentSandbox || len(hosts) != 2 || hosts[1] != "api.storekit-sandbox.itunes.apple.com" {
+		t.Errorf("TestService_SandboxFallback got environment:%s, hosts:%v", got.Environment, hosts)
+	}
+
+	refunds, err := service.GetRefundHistory(context.Background(), "1000", "")
+	if err != nil {
+		t.Errorf("TestService_SandboxFallback failed. err:%v", err)
+		return
+	}
+	if refunds.Environment() != EnvironmentSandbox {
+		t.Errorf("TestService_SandboxFallback got refund environment:%s", refunds.Environment())
+	}
+	if _, err := refunds.GetTransactions(); err != nil {
+		t.Errorf("TestService_SandboxFallback refund transactions failed. err:%v", err)
+	}
+
+	// 其他错误不查询 sandbox 环境
+	hosts = nil
+	if _, err := service.Sandbox(true).GetTransactionInfo(context.Background(), "2000"); err == nil || len(hosts) != 1 {
+		t.Errorf("TestService_SandboxFallback got err:%v, hosts:%v", err, hosts)
+	}
+}
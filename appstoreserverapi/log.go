@@ -0,0 +1,148 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLogBody 日志中请求及响应 body 的最大长度，超过部分截断
+const maxLogBody = 4096
+
+var (
+	// bearerPattern 匹配 Authorization header 中的 token
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9_\-.=]+`)
+	// jwsPattern 匹配 JWS compact 格式，header 以 {" 开头，base64url 编码后为 eyJ
+	jwsPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*`)
+)
+
+// WithLogger 设置记录请求日志的 slog.Logger，默认不记录日志
+// 每次请求以 Debug 级别记录 request_id、method、path、attempt、status、latency，
+// 请求失败时以 Warn 级别记录，并包含 error 及 Apple 的 error_code
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Service) {
+		s.logger = logger
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 设置请求日志中的 request_id，未设置时每次调用生成随机的 request_id，重试的请求使用相同的 request_id
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestLog 一次请求的日志信息
+type requestLog struct {
+	RequestID   string
+	Method      string
+	Path        string
+	Attempt     int
+	ContentType string
+	Body        []byte
+	StatusCode  int
+	Latency     time.Duration
+	Response    []byte
+	Err         error
+}
+
+// requestLogger 返回记录请求日志的 slog.Logger，没有设置 WithLogger 时，Debug(true) 使用 slog.Default()
+func (s *Service) requestLogger() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
+	}
+	if s.debug {
+		return slog.Default()
+	}
+	return nil
+}
+
+// logRequest 记录一次请求，Debug(true) 时以 Info 级别记录，并包含脱敏后的请求及响应 body
+func (s *Service) logRequest(ctx context.Context, l *requestLog) {
+	logger := s.requestLogger()
+	if logger == nil {
+		return
+	}
+
+	level := slog.LevelDebug
+	if s.debug {
+		level = slog.LevelInfo
+	}
+	if l.Err != nil && level < slog.LevelWarn {
+		level = slog.LevelWarn
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("request_id", l.RequestID),
+		slog.String("method", l.Method),
+		slog.String("path", l.Path),
+		slog.Bool("sandbox", s.sandbox),
+		slog.Int("attempt", l.Attempt),
+		slog.Int("status", l.StatusCode),
+		slog.Duration("latency", l.Latency),
+	}
+	if l.Err != nil {
+		if apiErr, ok := ApiErrorFromError(l.Err); ok && apiErr != nil {
+			attrs = append(attrs, slog.Int("error_code", apiErr.Code))
+		}
+		attrs = append(attrs, slog.String("error", redact(l.Err.Error())))
+	}
+	if s.debug {
+		attrs = append(attrs,
+			slog.String("request_body", logBody(l.ContentType, l.Body)),
+			slog.String("response_body", logBody("", l.Response)),
+		)
+	}
+
+	logger.LogAttrs(ctx, level, "appstore.appstoreserverapi request", attrs...)
+}
+
+// logBody 返回脱敏并截断后的 body，非文本内容只记录长度
+func logBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		return fmt.Sprintf("[%d bytes %s]", len(body), contentType)
+	}
+
+	out := redact(string(body))
+	if len(out) > maxLogBody {
+		// 不截断多字节字符
+		n := maxLogBody
+		for n > 0 && !utf8.RuneStart(out[n]) {
+			n--
+		}
+		out = fmt.Sprintf("%s...[truncated %d bytes]", out[:n], len(out)-n)
+	}
+	return out
+}
+
+// redact 隐藏 bearer token 及 JWS，JWS 只保留长度
+func redact(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "Bearer [REDACTED]")
+	return jwsPattern.ReplaceAllStringFunc(s, func(v string) string {
+		return fmt.Sprintf("[REDACTED JWS %d bytes]", len(v))
+	})
+}
@@ -0,0 +1,86 @@
+package appstoreserverapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestService_Logger(t *testing.T) {
+	signer, verifier := testSigner(t)
+	tx := testSign(t, signer, Transaction{TransactionID: "1000", BundleID: "com.example", Environment: EnvironmentProduction})
+
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/inApps/v1/transactions/2000" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"signedTransactionInfo": tx})
+	}).Verifier(verifier).Debug(true)
+
+	var buf bytes.Buffer
+	service.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	if _, err := service.GetTransactionInfo(ctx, "1000"); err != nil {
+		t.Errorf("TestService_Logger failed. err:%v", err)
+		return
+	}
+	if _, err := service.GetTransactionInfo(ctx, "2000"); err == nil {
+		t.Errorf("TestService_Logger: want error")
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Errorf("TestService_Logger got logs:%s", buf.String())
+		return
+	}
+
+	var ok, failed map[string]interface{}
+	json.Unmarshal([]byte(lines[0]), &ok)
+	json.Unmarshal([]byte(lines[1]), &failed)
+
+	if ok["request_id"] != "req-1" || ok["level"] != "INFO" || ok["status"] != float64(200) ||
+		ok["path"] != "/inApps/v1/transactions/1000" {
+		t.Errorf("TestService_Logger got log:%v", ok)
+	}
+	if body, _ := ok["response_body"].(string); strings.Contains(body, tx) || !strings.Contains(body, "[REDACTED JWS") {
+		t.Errorf("TestService_Logger response body not redacted:%s", body)
+	}
+
+	if failed["level"] != "WARN" || failed["error_code"] != float64(ErrCodeTransactionIDNotFound) || failed["status"] != float64(404) {
+		t.Errorf("TestService_Logger got log:%v", failed)
+	}
+
+	if token, _ := service.token.Get(); token == "" || strings.Contains(buf.String(), token) {
+		t.Errorf("TestService_Logger bearer token logged:%s", buf.String())
+	}
+}
+
+func TestLogBody(t *testing.T) {
+	if got := logBody("", []byte(`{"token":"Bearer abc.def-ghi"}`)); got != `{"token":"Bearer [REDACTED]"}` {
+		t.Errorf("TestLogBody got:%s", got)
+	}
+
+	if got := logBody("image/png", make([]byte, 10)); got != "[10 bytes image/png]" {
+		t.Errorf("TestLogBody got:%s", got)
+	}
+
+	got := logBody("application/json", bytes.Repeat([]byte("a"), maxLogBody+10))
+	if !strings.HasSuffix(got, "...[truncated 10 bytes]") || len(got) != maxLogBody+len("...[truncated 10 bytes]") {
+		t.Errorf("TestLogBody got truncated body len:%d", len(got))
+	}
+
+	// 截断位置在多字节字符中间时，保留完整的字符
+	got = logBody("", append([]byte("ab"), bytes.Repeat([]byte("中"), maxLogBody/3+1)...))
+	if !utf8.ValidString(got) || !strings.HasPrefix(got, "ab") {
+		t.Errorf("TestLogBody got invalid utf-8 after truncation")
+	}
+}
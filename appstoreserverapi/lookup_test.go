@@ -0,0 +1,55 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestService_LookupOrderDetail(t *testing.T) {
+	signer, verifier := testSigner(t)
+	sign := func(tx Transaction) JWSTransaction {
+		tx.BundleID, tx.Environment = "com.example", EnvironmentProduction
+		return JWSTransaction(testSign(t, signer, tx))
+	}
+
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/inApps/v1/lookup/VALID":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status": OrderLookupStatusValid,
+				"signedTransactions": []JWSTransaction{
+					sign(Transaction{TransactionID: "11", OriginalTransactionID: "10", Type: TransactionTypeAutoRenewableSubscription}),
+					sign(Transaction{TransactionID: "12", OriginalTransactionID: "10", Type: TransactionTypeAutoRenewableSubscription}),
+					sign(Transaction{TransactionID: "20", OriginalTransactionID: "20", Type: TransactionTypeConsumable}),
+				},
+			})
+		case "/inApps/v1/lookup/INVALID":
+			w.Write([]byte(`{"status":1}`))
+		case "/inApps/v1/subscriptions/10":
+			json.NewEncoder(w).Encode(GetAllSubscriptionStatusesResp{BundleID: "com.example", Environment: EnvironmentProduction})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Verifier(verifier)
+
+	got, err := service.LookupOrderDetail(context.Background(), "VALID")
+	if err != nil {
+		t.Errorf("TestService_LookupOrderDetail failed. err:%v", err)
+		return
+	}
+
+	if got.Status != OrderLookupStatusValid || len(got.Transactions["10"]) != 2 || len(got.Transactions["20"]) != 1 {
+		t.Errorf("TestService_LookupOrderDetail got transactions:%#v", got.Transactions)
+	}
+
+	if len(got.SubscriptionStatuses) != 1 || got.SubscriptionStatuses["10"] == nil {
+		t.Errorf("TestService_LookupOrderDetail got subscription statuses:%#v", got.SubscriptionStatuses)
+	}
+
+	res, err := service.LookupOrderID(context.Background(), "INVALID")
+	if err != nil || res.Status != OrderLookupStatusInvalid {
+		t.Errorf("TestService_LookupOrderDetail invalid got:%#v, err:%v", res, err)
+	}
+}
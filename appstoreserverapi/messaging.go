@@ -0,0 +1,295 @@
+package appstoreserverapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/beanscc/appstore/jws"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Retention Messaging API
+// https://developer.apple.com/documentation/retentionmessaging
+
+// MessagingState 图片或消息的审核状态
+type MessagingState string
+
+const (
+	// The image or message is awaiting approval
+	MessagingStatePending MessagingState = "PENDING"
+	// The image or message is approved
+	MessagingStateApproved MessagingState = "APPROVED"
+	// The image or message is rejected
+	MessagingStateRejected MessagingState = "REJECTED"
+)
+
+// UploadImage Upload an image to use for retention messaging
+//   - imageIdentifier: 由调用方生成的 UUID
+//   - image: PNG 格式的图片内容
+//
+// https://developer.apple.com/documentation/retentionmessaging/upload-image
+func (s *Service) UploadImage(ctx context.Context, imageIdentifier string, image []byte) error {
+	if !isUUID(imageIdentifier) {
+		return fmt.Errorf("appstore.appstoreserverapi.UploadImage: imageIdentifier is not a UUID:%q", imageIdentifier)
+	}
+	if len(image) == 0 {
+		return errors.New("appstore.appstoreserverapi.UploadImage: empty image")
+	}
+
+	_, _, err := s.requestWithContentType(ctx, "PUT", "/inApps/v1/messaging/image/"+imageIdentifier, "image/png", bytes.NewReader(image))
+	return err
+}
+
+// DeleteImage Delete a previously uploaded image
+// https://developer.apple.com/documentation/retentionmessaging/delete-image
+func (s *Service) DeleteImage(ctx context.Context, imageIdentifier string) error {
+	if !isUUID(imageIdentifier) {
+		return fmt.Errorf("appstore.appstoreserverapi.DeleteImage: imageIdentifier is not a UUID:%q", imageIdentifier)
+	}
+
+	_, _, err := s.request(ctx, "DELETE", "/inApps/v1/messaging/image/"+imageIdentifier, nil)
+	return err
+}
+
+// GetImageListResp A response that contains status information for all images
+// https://developer.apple.com/documentation/retentionmessaging/getimagelistresponse
+type GetImageListResp struct {
+	ImageIdentifiers []GetImageListRespItem `json:"imageIdentifiers"`
+}
+
+type GetImageListRespItem struct {
+	ImageIdentifier string         `json:"imageIdentifier"`
+	ImageState      MessagingState `json:"imageState"`
+}
+
+// GetImageList Get the image identifier and state for all uploaded images
+// https://developer.apple.com/documentation/retentionmessaging/get-image-list
+func (s *Service) GetImageList(ctx context.Context) (*GetImageListResp, error) {
+	_, body, err := s.get(ctx, "/inApps/v1/messaging/image/list", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out GetImageListResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UploadMessageImage The image identifier and alternative text of a message
+// https://developer.apple.com/documentation/retentionmessaging/uploadmessageimage
+type UploadMessageImage struct {
+	// [Required] The identifier of an image uploaded with UploadImage
+	ImageIdentifier string `json:"imageIdentifier"`
+	// [Required] The alternative text for the image, maximum length is 150 characters
+	AltText string `json:"altText"`
+}
+
+// UploadMessageRequest The request body for uploading a message, which includes the message text and an optional image reference
+// https://developer.apple.com/documentation/retentionmessaging/uploadmessagerequestbody
+type UploadMessageRequest struct {
+	// [Required] The header text of the retention message, maximum length is 66 characters
+	Header string `json:"header"`
+	// [Required] The body text of the retention message, maximum length is 144 characters
+	Body string `json:"body"`
+	// [Optional] The image of the retention message
+	Image *UploadMessageImage `json:"image,omitempty"`
+}
+
+// Validate 检查必填字段及长度限制
+func (r *UploadMessageRequest) Validate() error {
+	switch {
+	case r.Header == "" || len([]rune(r.Header)) > 66:
+		return errors.New("appstore.appstoreserverapi.UploadMessageRequest: header must be 1 to 66 characters")
+	case r.Body == "" || len([]rune(r.Body)) > 144:
+		return errors.New("appstore.appstoreserverapi.UploadMessageRequest: body must be 1 to 144 characters")
+	case r.Image != nil && !isUUID(r.Image.ImageIdentifier):
+		return fmt.Errorf("appstore.appstoreserverapi.UploadMessageRequest: image.imageIdentifier is not a UUID:%q", r.Image.ImageIdentifier)
+	case r.Image != nil && (r.Image.AltText == "" || len([]rune(r.Image.AltText)) > 150):
+		return errors.New("appstore.appstoreserverapi.UploadMessageRequest: image.altText must be 1 to 150 characters")
+	}
+
+	return nil
+}
+
+// UploadMessage Upload a message to use for retention messaging
+//   - messageIdentifier: 由调用方生成的 UUID
+//
+// https://developer.apple.com/documentation/retentionmessaging/upload-message
+func (s *Service) UploadMessage(ctx context.Context, messageIdentifier string, req *UploadMessageRequest) error {
+	if !isUUID(messageIdentifier) {
+		return fmt.Errorf("appstore.appstoreserverapi.UploadMessage: messageIdentifier is not a UUID:%q", messageIdentifier)
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	_, _, err := s.requestJSON(ctx, "PUT", "/inApps/v1/messaging/message/"+messageIdentifier, req)
+	return err
+}
+
+// DeleteMessage Delete a previously uploaded message
+// https://developer.apple.com/documentation/retentionmessaging/delete-message
+func (s *Service) DeleteMessage(ctx context.Context, messageIdentifier string) error {
+	if !isUUID(messageIdentifier) {
+		return fmt.Errorf("appstore.appstoreserverapi.DeleteMessage: messageIdentifier is not a UUID:%q", messageIdentifier)
+	}
+
+	_, _, err := s.request(ctx, "DELETE", "/inApps/v1/messaging/message/"+messageIdentifier, nil)
+	return err
+}
+
+// GetMessageListResp A response that contains status information for all messages
+// https://developer.apple.com/documentation/retentionmessaging/getmessagelistresponse
+type GetMessageListResp struct {
+	MessageIdentifiers []GetMessageListRespItem `json:"messageIdentifiers"`
+}
+
+type GetMessageListRespItem struct {
+	MessageIdentifier string         `json:"messageIdentifier"`
+	MessageState      MessagingState `json:"messageState"`
+}
+
+// GetMessageList Get the message identifier and state of all uploaded messages
+// https://developer.apple.com/documentation/retentionmessaging/get-message-list
+func (s *Service) GetMessageList(ctx context.Context) (*GetMessageListResp, error) {
+	_, body, err := s.get(ctx, "/inApps/v1/messaging/message/list", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out GetMessageListResp
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DefaultConfigurationRequest The request body that contains the default configuration information
+// https://developer.apple.com/documentation/retentionmessaging/defaultconfigurationrequest
+type DefaultConfigurationRequest struct {
+	// [Required] The message identifier of an approved message
+	MessageIdentifier string `json:"messageIdentifier"`
+}
+
+// ConfigureDefaultMessage Configure a default message for a specific product in a specific locale
+// https://developer.apple.com/documentation/retentionmessaging/configure-default-message
+func (s *Service) ConfigureDefaultMessage(ctx context.Context, productID string, locale string, messageIdentifier string) error {
+	if productID == "" || locale == "" {
+		return errors.New("appstore.appstoreserverapi.ConfigureDefaultMessage: empty productId or locale")
+	}
+	if !isUUID(messageIdentifier) {
+		return fmt.Errorf("appstore.appstoreserverapi.ConfigureDefaultMessage: messageIdentifier is not a UUID:%q", messageIdentifier)
+	}
+
+	req := &DefaultConfigurationRequest{MessageIdentifier: messageIdentifier}
+	_, _, err := s.requestJSON(ctx, "PUT", defaultMessagePath(productID, locale), req)
+	return err
+}
+
+// DeleteDefaultMessage Delete a default message for a product in a locale
+// https://developer.apple.com/documentation/retentionmessaging/delete-default-message
+func (s *Service) DeleteDefaultMessage(ctx context.Context, productID string, locale string) error {
+	if productID == "" || locale == "" {
+		return errors.New("appstore.appstoreserverapi.DeleteDefaultMessage: empty productId or locale")
+	}
+
+	_, _, err := s.request(ctx, "DELETE", defaultMessagePath(productID, locale), nil)
+	return err
+}
+
+func defaultMessagePath(productID string, locale string) string {
+	return "/inApps/v1/messaging/default/" + url.PathEscape(productID) + "/" + url.PathEscape(locale)
+}
+
+// RealtimeRequest The decoded request body the App Store sends to your server to request a real-time retention message
+// https://developer.apple.com/documentation/retentionmessaging/decodedrealtimerequestbody
+type RealtimeRequest struct {
+	// The original transaction identifier of the customer’s subscription
+	OriginalTransactionID string `json:"originalTransactionId"`
+	// The unique identifier of the app in the App Store
+	AppAppleID int64 `json:"appAppleId"`
+	// The unique identifier of the auto-renewable subscription
+	ProductID string `json:"productId"`
+	// The device’s locale
+	UserLocale string `json:"userLocale"`
+	// A UUID the App Store server creates to uniquely identify each request
+	RequestIdentifier string `json:"requestIdentifier"`
+	// The server environment, either sandbox or production
+	Environment Environment `json:"environment"`
+	// The UNIX time, in milliseconds, that the App Store signed the JSON Web Signature (JWS) data
+	SignedDate int64 `json:"signedDate"`
+}
+
+// JWSRealtimeRequest App Store 请求实时挽留消息时发送的 signedPayload
+// https://developer.apple.com/documentation/retentionmessaging/realtimerequestbody
+type JWSRealtimeRequest string
+
+// GetRealtimeRequest 使用 jws.DefaultVerifier 验证并解码实时挽留消息请求
+func (s JWSRealtimeRequest) GetRealtimeRequest() (*RealtimeRequest, error) {
+	return s.Verify(jws.DefaultVerifier())
+}
+
+// Verify 使用 verifier 验证并解码实时挽留消息请求
+func (s JWSRealtimeRequest) Verify(verifier *jws.Verifier) (*RealtimeRequest, error) {
+	val, err := jws.Parse(string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	type Payload struct {
+		jwt.RegisteredClaims
+		RealtimeRequest
+	}
+	var out Payload
+	if err := verifier.VerifyAndBind(val, &out); err != nil {
+		return nil, err
+	}
+
+	return &out.RealtimeRequest, nil
+}
+
+// RealtimeRequest 验证并解码实时挽留消息请求，断言 appAppleId 及 environment
+func (v *SignedDataVerifier) RealtimeRequest(s JWSRealtimeRequest) (*RealtimeRequest, error) {
+	out, err := s.Verify(v.jwsVerifier())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.checkAppAppleID(out.AppAppleID, out.Environment); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(out.Environment); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RealtimeResponseMessage A retention message that’s text-based and can include an optional image
+// https://developer.apple.com/documentation/retentionmessaging/message
+type RealtimeResponseMessage struct {
+	MessageIdentifier string `json:"messageIdentifier"`
+}
+
+// RealtimeResponseAlternateProduct A switch-plan message and product ID you provide in a real-time response
+// https://developer.apple.com/documentation/retentionmessaging/alternateproduct
+type RealtimeResponseAlternateProduct struct {
+	MessageIdentifier string `json:"messageIdentifier"`
+	ProductID         string `json:"productId"`
+}
+
+// RealtimeResponse The response body you send to the App Store server for a real-time retention message request
+// Message 与 AlternateProduct 只能设置其中一个
+// https://developer.apple.com/documentation/retentionmessaging/realtimeresponsebody
+type RealtimeResponse struct {
+	Message          *RealtimeResponseMessage          `json:"message,omitempty"`
+	AlternateProduct *RealtimeResponseAlternateProduct `json:"alternateProduct,omitempty"`
+}
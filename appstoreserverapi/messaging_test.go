@@ -0,0 +1,110 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestService_RetentionMessaging(t *testing.T) {
+	const (
+		imageID   = "0e2f5b36-7a2c-4f7c-9a43-2f1b3d5e6a70"
+		messageID = "a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d"
+	)
+
+	got := make(map[string]string)
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got[r.Method+" "+r.URL.EscapedPath()] = r.Header.Get("Content-Type") + " " + string(body)
+
+		switch r.URL.Path {
+		case "/inApps/v1/messaging/image/list":
+			w.Write([]byte(`{"imageIdentifiers":[{"imageIdentifier":"` + imageID + `","imageState":"APPROVED"}]}`))
+		case "/inApps/v1/messaging/message/list":
+			w.Write([]byte(`{"messageIdentifiers":[{"messageIdentifier":"` + messageID + `","messageState":"PENDING"}]}`))
+		}
+	})
+
+	ctx := context.Background()
+	if err := service.UploadImage(ctx, imageID, []byte("\x89PNG")); err != nil {
+		t.Errorf("TestService_RetentionMessaging UploadImage failed. err:%v", err)
+		return
+	}
+
+	req := &UploadMessageRequest{Header: "Stay with us", Body: "Get 50% off", Image: &UploadMessageImage{ImageIdentifier: imageID, AltText: "offer"}}
+	if err := service.UploadMessage(ctx, messageID, req); err != nil {
+		t.Errorf("TestService_RetentionMessaging UploadMessage failed. err:%v", err)
+		return
+	}
+
+	if err := service.ConfigureDefaultMessage(ctx, "com.example.monthly", "en-US", messageID); err != nil {
+		t.Errorf("TestService_RetentionMessaging ConfigureDefaultMessage failed. err:%v", err)
+		return
+	}
+
+	images, err := service.GetImageList(ctx)
+	if err != nil || len(images.ImageIdentifiers) != 1 || images.ImageIdentifiers[0].ImageState != MessagingStateApproved {
+		t.Errorf("TestService_RetentionMessaging GetImageList got:%#v, err:%v", images, err)
+	}
+
+	messages, err := service.GetMessageList(ctx)
+	if err != nil || len(messages.MessageIdentifiers) != 1 || messages.MessageIdentifiers[0].MessageState != MessagingStatePending {
+		t.Errorf("TestService_RetentionMessaging GetMessageList got:%#v, err:%v", messages, err)
+	}
+
+	if err := service.DeleteMessage(ctx, messageID); err != nil {
+		t.Errorf("TestService_RetentionMessaging DeleteMessage failed. err:%v", err)
+	}
+
+	want := map[string]string{
+		"PUT /inApps/v1/messaging/image/" + imageID:                  "image/png \x89PNG",
+		"PUT /inApps/v1/messaging/message/" + messageID:              `application/json {"header":"Stay with us","body":"Get 50% off","image":{"imageIdentifier":"` + imageID + `","altText":"offer"}}`,
+		"PUT /inApps/v1/messaging/default/com.example.monthly/en-US": `application/json {"messageIdentifier":"` + messageID + `"}`,
+		"GET /inApps/v1/messaging/image/list":                        " ",
+		"GET /inApps/v1/messaging/message/list":                      " ",
+		"DELETE /inApps/v1/messaging/message/" + messageID:           " ",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("TestService_RetentionMessaging %s got:%q, want:%q", k, got[k], v)
+		}
+	}
+
+	if err := service.UploadMessage(ctx, messageID, &UploadMessageRequest{Header: "", Body: "x"}); err == nil {
+		t.Errorf("TestService_RetentionMessaging: empty header should be rejected")
+	}
+
+	// 标识不是 UUID 时不发送请求
+	if err := service.DeleteImage(ctx, "../message/"+messageID); err == nil {
+		t.Errorf("TestService_RetentionMessaging: DeleteImage should reject non-UUID identifier")
+	}
+	if err := service.DeleteMessage(ctx, "list"); err == nil {
+		t.Errorf("TestService_RetentionMessaging: DeleteMessage should reject non-UUID identifier")
+	}
+	if len(got) != len(want) {
+		t.Errorf("TestService_RetentionMessaging got requests:%v", got)
+	}
+}
+
+func TestSignedDataVerifier_RealtimeRequest(t *testing.T) {
+	signer, verifier := testSigner(t)
+	v := NewSignedDataVerifier("com.example", 1234, EnvironmentProduction, verifier)
+
+	in := RealtimeRequest{OriginalTransactionID: "1000", AppAppleID: 1234, ProductID: "com.example.monthly", UserLocale: "en-US", Environment: EnvironmentProduction}
+	out, err := v.RealtimeRequest(JWSRealtimeRequest(testSign(t, signer, in)))
+	if err != nil {
+		t.Errorf("TestSignedDataVerifier_RealtimeRequest failed. err:%v", err)
+		return
+	}
+
+	if *out != in {
+		t.Errorf("TestSignedDataVerifier_RealtimeRequest got:%#v", out)
+	}
+
+	resp, _ := json.Marshal(RealtimeResponse{Message: &RealtimeResponseMessage{MessageIdentifier: "m"}})
+	if string(resp) != `{"message":{"messageIdentifier":"m"}}` {
+		t.Errorf("TestSignedDataVerifier_RealtimeRequest got response:%s", resp)
+	}
+}
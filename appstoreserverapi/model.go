@@ -1,6 +1,11 @@
 package appstoreserverapi
 
 import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
 	"github.com/beanscc/appstore/jws"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -11,6 +16,9 @@ type Environment string
 const (
 	EnvironmentSandbox    Environment = "Sandbox"
 	EnvironmentProduction Environment = "Production"
+	// 仅出现在 AppTransaction.ReceiptType 中
+	EnvironmentXcode        Environment = "Xcode"
+	EnvironmentLocalTesting Environment = "LocalTesting"
 )
 
 // InAppOwnershipType https://developer.apple.com/documentation/appstoreserverapi/inappownershiptype
@@ -150,7 +158,14 @@ type Transaction struct {
 
 type JWSTransaction string
 
+// GetTransaction 使用 jws.DefaultVerifier 验证并解码交易信息
+// 默认按 payload 的 signedDate 验证证书有效期，参见 jws.TimePolicy
 func (s JWSTransaction) GetTransaction() (*Transaction, error) {
+	return s.Verify(jws.DefaultVerifier())
+}
+
+// Verify 使用 verifier 验证并解码交易信息
+func (s JWSTransaction) Verify(verifier *jws.Verifier) (*Transaction, error) {
 	val, err := jws.Parse(string(s))
 	if err != nil {
 		return nil, err
@@ -161,7 +176,7 @@ func (s JWSTransaction) GetTransaction() (*Transaction, error) {
 		Transaction
 	}
 	var out Payload
-	if err := val.VerifyAndBind(&out); err != nil {
+	if err := verifier.VerifyAndBind(val, &out); err != nil {
 		return nil, err
 	}
 
@@ -240,7 +255,14 @@ type RenewalInfo struct {
 
 type JWSRenewalInfo string
 
+// GetRenewInfo 使用 jws.DefaultVerifier 验证并解码续订信息
+// 默认按 payload 的 signedDate 验证证书有效期，参见 jws.TimePolicy
 func (s JWSRenewalInfo) GetRenewInfo() (*RenewalInfo, error) {
+	return s.Verify(jws.DefaultVerifier())
+}
+
+// Verify 使用 verifier 验证并解码续订信息
+func (s JWSRenewalInfo) Verify(verifier *jws.Verifier) (*RenewalInfo, error) {
 	val, err := jws.Parse(string(s))
 	if err != nil {
 		return nil, err
@@ -250,13 +272,92 @@ func (s JWSRenewalInfo) GetRenewInfo() (*RenewalInfo, error) {
 		RenewalInfo
 	}
 	var out Payload
-	if err := val.VerifyAndBind(&out); err != nil {
+	if err := verifier.VerifyAndBind(val, &out); err != nil {
 		return nil, err
 	}
 
 	return &out.RenewalInfo, nil
 }
 
+// AppTransaction Information that represents the customer’s purchase of the app, cryptographically signed by the App Store
+// https://developer.apple.com/documentation/storekit/apptransaction
+type AppTransaction struct {
+	// The unique identifier the App Store uses to identify the app. It's 0 in the sandbox environment
+	AppAppleID int64 `json:"appAppleId,omitempty"`
+	// The bundle identifier that the app transaction applies to
+	BundleID string `json:"bundleId,omitempty"`
+	// The app version that the app transaction applies to
+	ApplicationVersion string `json:"applicationVersion,omitempty"`
+	// The version external identifier of the app, 0 in the sandbox environment
+	VersionExternalIdentifier int64 `json:"versionExternalIdentifier,omitempty"`
+	// The date that the App Store signed the JWS app transaction, UNIX time in milliseconds
+	ReceiptCreationDate int64 `json:"receiptCreationDate,omitempty"`
+	// The date the user originally purchased the app from the App Store, UNIX time in milliseconds
+	OriginalPurchaseDate int64 `json:"originalPurchaseDate,omitempty"`
+	// The app version that the user originally purchased from the App Store
+	OriginalApplicationVersion string `json:"originalApplicationVersion,omitempty"`
+	// The Base64 encoded SHA-384 hash used to verify that the app transaction belongs to the device, see VerifyDevice
+	DeviceVerification string `json:"deviceVerification,omitempty"`
+	// The UUID used to compute the device verification value
+	DeviceVerificationNonce string `json:"deviceVerificationNonce,omitempty"`
+	// The date the customer placed an order for the app before it’s available in the App Store, UNIX time in milliseconds.
+	// 0 if the customer didn't pre-order the app
+	PreorderDate int64 `json:"preorderDate,omitempty"`
+	// The server environment that signs the app transaction
+	ReceiptType Environment `json:"receiptType,omitempty"`
+	// The unique identifier of the app download transaction
+	AppTransactionID string `json:"appTransactionId,omitempty"`
+	// The platform on which the customer originally purchased the app: iOS, macOS, tvOS, visionOS
+	OriginalPlatform string `json:"originalPlatform,omitempty"`
+	// The UNIX time, in milliseconds, that the App Store signed the JSON Web Signature data
+	SignedDate int64 `json:"signedDate,omitempty"`
+}
+
+// VerifyDevice 检查 app transaction 是否属于 deviceID 所代表的设备
+// deviceID 为客户端上报的 AppStore.deviceVerificationID（iOS 上即 identifierForVendor）
+// deviceVerification = base64(SHA384(lowercase(deviceVerificationNonce) + lowercase(deviceID)))
+func (a *AppTransaction) VerifyDevice(deviceID string) bool {
+	if a.DeviceVerification == "" || a.DeviceVerificationNonce == "" || deviceID == "" {
+		return false
+	}
+
+	want, err := base64.StdEncoding.DecodeString(a.DeviceVerification)
+	if err != nil {
+		return false
+	}
+
+	sum := sha512.Sum384([]byte(strings.ToLower(a.DeviceVerificationNonce) + strings.ToLower(deviceID)))
+	return subtle.ConstantTimeCompare(sum[:], want) == 1
+}
+
+// JWSAppTransaction 客户端通过 AppTransaction.shared 获取的 jwsRepresentation
+type JWSAppTransaction string
+
+// GetAppTransaction 使用 jws.DefaultVerifier 验证并解码 app transaction
+// 默认按 payload 的 signedDate 验证证书有效期，参见 jws.TimePolicy
+func (s JWSAppTransaction) GetAppTransaction() (*AppTransaction, error) {
+	return s.Verify(jws.DefaultVerifier())
+}
+
+// Verify 使用 verifier 验证并解码 app transaction
+func (s JWSAppTransaction) Verify(verifier *jws.Verifier) (*AppTransaction, error) {
+	val, err := jws.Parse(string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	type Payload struct {
+		jwt.RegisteredClaims
+		AppTransaction
+	}
+	var out Payload
+	if err := verifier.VerifyAndBind(val, &out); err != nil {
+		return nil, err
+	}
+
+	return &out.AppTransaction, nil
+}
+
 type SubscriptionGroupIdentifierItem struct {
 	SubscriptionGroupIdentifier string                         `json:"subscriptionGroupIdentifier"`
 	LastTransactions            []SubscriptionLastTransactions `json:"lastTransactions"`
@@ -293,6 +394,32 @@ const (
 	NotificationV2TypeTest                 NotificationV2Type = "TEST"
 )
 
+// notificationV2Types 已定义的 NotificationV2Type，新增常量时须同时添加
+var notificationV2Types = map[NotificationV2Type]bool{
+	NotificationV2TypeConsumptionRequest:   true,
+	NotificationV2TypeDidChangeRenewPref:   true,
+	NotificationV2TypeDidChangeRenewStatus: true,
+	NotificationV2TypeDidFailToRenew:       true,
+	NotificationV2TypeDidRenew:             true,
+	NotificationV2TypeExpired:              true,
+	NotificationV2TypeGracePeriodExpired:   true,
+	NotificationV2TypeOfferRedeemed:        true,
+	NotificationV2TypePriceIncrease:        true,
+	NotificationV2TypeRefund:               true,
+	NotificationV2TypeRefundDeclined:       true,
+	NotificationV2TypeRefundReversed:       true,
+	NotificationV2TypeRenewalExtended:      true,
+	NotificationV2TypeRenewalExtension:     true,
+	NotificationV2TypeRevoke:               true,
+	NotificationV2TypeSubscribed:           true,
+	NotificationV2TypeTest:                 true,
+}
+
+// Known 是否为本包已定义的通知类型
+func (t NotificationV2Type) Known() bool {
+	return notificationV2Types[t]
+}
+
 // NotificationV2Subtype A string that provides details about select notification types in version 2
 // https://developer.apple.com/documentation/appstoreservernotifications/subtype
 type NotificationV2Subtype string
@@ -323,7 +450,14 @@ type NotificationHistoryItem struct {
 
 type JWSNotification string
 
+// GetNotification 使用 jws.DefaultVerifier 验证并解码通知
+// 默认按 payload 的 signedDate 验证证书有效期，参见 jws.TimePolicy
 func (s JWSNotification) GetNotification() (*NotificationV2, error) {
+	return s.Verify(jws.DefaultVerifier())
+}
+
+// Verify 使用 verifier 验证并解码通知
+func (s JWSNotification) Verify(verifier *jws.Verifier) (*NotificationV2, error) {
 	val, err := jws.Parse(string(s))
 	if err != nil {
 		return nil, err
@@ -333,7 +467,7 @@ func (s JWSNotification) GetNotification() (*NotificationV2, error) {
 		NotificationV2
 	}
 	var out Payload
-	if err := val.VerifyAndBind(&out); err != nil {
+	if err := verifier.VerifyAndBind(val, &out); err != nil {
 		return nil, err
 	}
 
@@ -347,9 +481,11 @@ type NotificationV2 struct {
 	Subtype          NotificationV2Subtype `json:"subtype"`
 	Data             NotificationV2Data    `json:"data"`
 	Summary          NotificationV2Summary `json:"summary"`
-	Version          string                `json:"version"`
-	SignedDate       int64                 `json:"signedDate"`
-	NotificationUUID string                `json:"notificationUUID"`
+	// ExternalPurchaseToken 仅 EXTERNAL_PURCHASE_TOKEN 通知包含，此时没有 data 及 summary
+	ExternalPurchaseToken NotificationV2ExternalPurchaseToken `json:"externalPurchaseToken"`
+	Version               string                              `json:"version"`
+	SignedDate            int64                               `json:"signedDate"`
+	NotificationUUID      string                              `json:"notificationUUID"`
 }
 
 type NotificationV2Data struct {
@@ -374,6 +510,22 @@ type NotificationV2Summary struct {
 	SucceededCount         int64       `json:"succeededCount"`
 }
 
+// NotificationV2ExternalPurchaseToken https://developer.apple.com/documentation/appstoreservernotifications/externalpurchasetoken
+type NotificationV2ExternalPurchaseToken struct {
+	ExternalPurchaseID string `json:"externalPurchaseId"`
+	TokenCreationDate  int64  `json:"tokenCreationDate"`
+	AppAppleID         int64  `json:"appAppleId"`
+	BundleID           string `json:"bundleId"`
+}
+
+// Environment externalPurchaseToken 不包含 environment，sandbox 环境的 externalPurchaseId 以 SANDBOX 开头
+func (t NotificationV2ExternalPurchaseToken) Environment() Environment {
+	if strings.HasPrefix(t.ExternalPurchaseID, "SANDBOX") {
+		return EnvironmentSandbox
+	}
+	return EnvironmentProduction
+}
+
 type NotificationSendAttemptItem struct {
 	AttemptDate       int64                         `json:"attemptDate"`
 	SendAttemptResult NotificationSendAttemptResult `json:"sendAttemptResult"`
@@ -396,3 +548,129 @@ const (
 	NotificationSendAttemptResultUnsuccessfulHttpResponseCode NotificationSendAttemptResult = "UNSUCCESSFUL_HTTP_RESPONSE_CODE"
 	NotificationSendAttemptResultUnsupportedCharset           NotificationSendAttemptResult = "UNSUPPORTED_CHARSET"
 )
+
+// AccountTenure The age of the customer’s account
+// https://developer.apple.com/documentation/appstoreserverapi/accounttenure
+type AccountTenure int32
+
+const (
+	AccountTenureUndeclared                       AccountTenure = 0
+	AccountTenureZeroToThreeDays                  AccountTenure = 1
+	AccountTenureThreeDaysToTenDays               AccountTenure = 2
+	AccountTenureTenDaysToThirtyDays              AccountTenure = 3
+	AccountTenureThirtyDaysToNinetyDays           AccountTenure = 4
+	AccountTenureNinetyDaysToOneHundredEightyDays AccountTenure = 5
+	AccountTenureOneHundredEightyDaysToOneYear    AccountTenure = 6
+	AccountTenureGreaterThanOneYear               AccountTenure = 7
+)
+
+// ConsumptionStatus A value that indicates the extent to which the customer consumed the in-app purchase
+// https://developer.apple.com/documentation/appstoreserverapi/consumptionstatus
+type ConsumptionStatus int32
+
+const (
+	ConsumptionStatusUndeclared        ConsumptionStatus = 0
+	ConsumptionStatusNotConsumed       ConsumptionStatus = 1
+	ConsumptionStatusPartiallyConsumed ConsumptionStatus = 2
+	ConsumptionStatusFullyConsumed     ConsumptionStatus = 3
+)
+
+// DeliveryStatus A value that indicates whether the app successfully delivered an in-app purchase that works properly
+// https://developer.apple.com/documentation/appstoreserverapi/deliverystatus
+type DeliveryStatus int32
+
+const (
+	DeliveryStatusDeliveredAndWorkingProperly DeliveryStatus = 0
+	DeliveryStatusDidNotDeliverQualityIssue   DeliveryStatus = 1
+	DeliveryStatusDeliveredWrongItem          DeliveryStatus = 2
+	DeliveryStatusDidNotDeliverServerOutage   DeliveryStatus = 3
+	DeliveryStatusDidNotDeliverCurrencyChange DeliveryStatus = 4
+	DeliveryStatusDidNotDeliverOtherReason    DeliveryStatus = 5
+)
+
+// LifetimeDollars A value that indicates the dollar amount, in USD, of in-app purchases the customer has made or
+// the App Store refunded across all platforms
+//   - https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarspurchased
+//   - https://developer.apple.com/documentation/appstoreserverapi/lifetimedollarsrefunded
+type LifetimeDollars int32
+
+const (
+	LifetimeDollarsUndeclared                      LifetimeDollars = 0
+	LifetimeDollarsZero                            LifetimeDollars = 1
+	LifetimeDollarsOneCentToFiftyDollars           LifetimeDollars = 2
+	LifetimeDollarsFiftyToOneHundredDollars        LifetimeDollars = 3
+	LifetimeDollarsOneHundredToFiveHundredDollars  LifetimeDollars = 4
+	LifetimeDollarsFiveHundredToOneThousandDollars LifetimeDollars = 5
+	LifetimeDollarsOneThousandToTwoThousandDollars LifetimeDollars = 6
+	LifetimeDollarsTwoThousandOrGreater            LifetimeDollars = 7
+)
+
+// Platform The platform on which the customer consumed the in-app purchase
+// https://developer.apple.com/documentation/appstoreserverapi/platform
+type Platform int32
+
+const (
+	PlatformUndeclared Platform = 0
+	PlatformApple      Platform = 1
+	PlatformNonApple   Platform = 2
+)
+
+// PlayTime A value that indicates the amount of time that the customer used the app
+// https://developer.apple.com/documentation/appstoreserverapi/playtime
+type PlayTime int32
+
+const (
+	PlayTimeUndeclared            PlayTime = 0
+	PlayTimeZeroToFiveMinutes     PlayTime = 1
+	PlayTimeFiveToSixtyMinutes    PlayTime = 2
+	PlayTimeOneToSixHours         PlayTime = 3
+	PlayTimeSixToTwentyFourHours  PlayTime = 4
+	PlayTimeOneDayToFourDays      PlayTime = 5
+	PlayTimeFourDaysToSixteenDays PlayTime = 6
+	PlayTimeOverSixteenDays       PlayTime = 7
+)
+
+// RefundPreference A value that indicates your preferred outcome for the refund request
+// https://developer.apple.com/documentation/appstoreserverapi/refundpreference
+type RefundPreference int32
+
+const (
+	RefundPreferenceUndeclared    RefundPreference = 0
+	RefundPreferencePreferGrant   RefundPreference = 1
+	RefundPreferencePreferDecline RefundPreference = 2
+	RefundPreferenceNoPreference  RefundPreference = 3
+)
+
+// UserStatus The status of a customer’s account within your app
+// https://developer.apple.com/documentation/appstoreserverapi/userstatus
+type UserStatus int32
+
+const (
+	UserStatusUndeclared    UserStatus = 0
+	UserStatusActive        UserStatus = 1
+	UserStatusSuspended     UserStatus = 2
+	UserStatusTerminated    UserStatus = 3
+	UserStatusLimitedAccess UserStatus = 4
+)
+
+// ExtendReasonCode The code that represents the reason for the subscription-renewal-date extension
+// https://developer.apple.com/documentation/appstoreserverapi/extendreasoncode
+type ExtendReasonCode int32
+
+const (
+	ExtendReasonCodeUndeclared           ExtendReasonCode = 0
+	ExtendReasonCodeCustomerSatisfaction ExtendReasonCode = 1
+	ExtendReasonCodeOther                ExtendReasonCode = 2
+	ExtendReasonCodeServiceIssueOrOutage ExtendReasonCode = 3
+)
+
+// OrderLookupStatus A value that indicates whether the order ID in the request is valid for your app
+// https://developer.apple.com/documentation/appstoreserverapi/orderlookupstatus
+type OrderLookupStatus int32
+
+const (
+	// The order ID is valid
+	OrderLookupStatusValid OrderLookupStatus = 0
+	// The order ID is invalid
+	OrderLookupStatusInvalid OrderLookupStatus = 1
+)
@@ -0,0 +1,48 @@
+package appstoreserverapi
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// TestNotificationV2Type_Known 检查 model.go 中所有 NotificationV2Type 常量均已加入 notificationV2Types
+func TestNotificationV2Type_Known(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "model.go", nil, 0)
+	if err != nil {
+		t.Fatalf("parser.ParseFile failed. err:%v", err)
+	}
+
+	var consts int
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			v := spec.(*ast.ValueSpec)
+			if ident, ok := v.Type.(*ast.Ident); !ok || ident.Name != "NotificationV2Type" {
+				continue
+			}
+
+			for i, name := range v.Names {
+				value, _ := strconv.Unquote(v.Values[i].(*ast.BasicLit).Value)
+				if !NotificationV2Type(value).Known() {
+					t.Errorf("TestNotificationV2Type_Known: %s is missing from notificationV2Types", name.Name)
+				}
+				consts++
+			}
+		}
+	}
+
+	if consts != len(notificationV2Types) {
+		t.Errorf("TestNotificationV2Type_Known got consts:%d, notificationV2Types:%d", consts, len(notificationV2Types))
+	}
+
+	if NotificationV2Type("NOT_DEFINED").Known() {
+		t.Errorf("TestNotificationV2Type_Known: undefined type should not be known")
+	}
+}
@@ -0,0 +1,234 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 接口模板，用于配置 RateLimiter 的配额，格式为 "METHOD /path"，path 中 {xxx} 匹配任意一段
+// https://developer.apple.com/documentation/appstoreserverapi/identifying_rate_limits
+const (
+	EndpointLookupOrderID                                = "GET /inApps/v1/lookup/{orderId}"
+	EndpointGetTransactionInfo                           = "GET /inApps/v1/transactions/{transactionId}"
+	EndpointSetAppAccountToken                           = "PUT /inApps/v1/transactions/{originalTransactionId}/appAccountToken"
+	EndpointGetTransactionHistory                        = "GET /inApps/v1/history/{transactionId}"
+	EndpointGetAllSubscriptionStatuses                   = "GET /inApps/v1/subscriptions/{transactionId}"
+	EndpointGetRefundHistory                             = "GET /inApps/v2/refund/lookup/{transactionId}"
+	EndpointGetNotificationHistory                       = "POST /inApps/v1/notifications/history"
+	EndpointRequestTestNotification                      = "POST /inApps/v1/notifications/test"
+	EndpointGetTestNotificationStatus                    = "GET /inApps/v1/notifications/test/{testNotificationToken}"
+	EndpointSendConsumptionInformation                   = "PUT /inApps/v1/transactions/consumption/{transactionId}"
+	EndpointExtendSubscriptionRenewalDate                = "PUT /inApps/v1/subscriptions/extend/{originalTransactionId}"
+	EndpointExtendRenewalDateForAllActiveSubscribers     = "POST /inApps/v1/subscriptions/extend/mass"
+	EndpointGetStatusOfSubscriptionRenewalDateExtensions = "GET /inApps/v1/subscriptions/extend/mass/{productId}/{requestIdentifier}"
+	EndpointUploadImage                                  = "PUT /inApps/v1/messaging/image/{imageIdentifier}"
+	EndpointDeleteImage                                  = "DELETE /inApps/v1/messaging/image/{imageIdentifier}"
+	EndpointGetImageList                                 = "GET /inApps/v1/messaging/image/list"
+	EndpointUploadMessage                                = "PUT /inApps/v1/messaging/message/{messageIdentifier}"
+	EndpointDeleteMessage                                = "DELETE /inApps/v1/messaging/message/{messageIdentifier}"
+	EndpointGetMessageList                               = "GET /inApps/v1/messaging/message/list"
+	EndpointConfigureDefaultMessage                      = "PUT /inApps/v1/messaging/default/{productId}/{locale}"
+	EndpointDeleteDefaultMessage                         = "DELETE /inApps/v1/messaging/default/{productId}/{locale}"
+)
+
+// ErrRateLimited 超过客户端配置的接口配额
+var ErrRateLimited = errors.New("appstore.appstoreserverapi: client-side rate limit exceeded")
+
+// RateLimitMode 超过配额时的处理方式
+type RateLimitMode int
+
+const (
+	// RateLimitWait 等待直到有可用配额或 ctx 结束
+	RateLimitWait RateLimitMode = iota
+	// RateLimitFailFast 立即返回 ErrRateLimited
+	RateLimitFailFast
+)
+
+// Quota 接口配额：每 Per 时间内最多 Limit 次请求，允许突发 Limit 次
+type Quota struct {
+	Limit int
+	Per   time.Duration
+}
+
+// RateLimiter 按接口模板分别限流的令牌桶，可在多个 Service 间共享
+// 没有配置配额的接口不限流
+type RateLimiter struct {
+	mode      RateLimitMode
+	endpoints []endpoint
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// NewRateLimiter quotas 的 key 为接口模板，参见 EndpointGetTransactionHistory 等，
+// 也可省略 METHOD，如 "/inApps/v1/history/{transactionId}"，匹配所有 METHOD
+func NewRateLimiter(quotas map[string]Quota, mode RateLimitMode) *RateLimiter {
+	l := &RateLimiter{
+		mode:    mode,
+		buckets: make(map[string]*bucket, len(quotas)),
+	}
+
+	for template, quota := range quotas {
+		if quota.Limit <= 0 || quota.Per <= 0 {
+			continue
+		}
+
+		e := endpoint{template: template}
+		path := template
+		if i := strings.IndexByte(template, ' '); i >= 0 {
+			e.method, path = template[:i], strings.TrimSpace(template[i+1:])
+		}
+		e.segments = strings.Split(strings.Trim(path, "/"), "/")
+
+		l.endpoints = append(l.endpoints, e)
+		l.buckets[template] = &bucket{
+			tokens: float64(quota.Limit),
+			burst:  float64(quota.Limit),
+			rate:   float64(quota.Limit) / quota.Per.Seconds(),
+		}
+	}
+
+	return l
+}
+
+// RateLimiter 设置客户端限流，Debug、Sandbox 等返回的 Service 共享同一个 RateLimiter
+func (s *Service) RateLimiter(limiter *RateLimiter) *Service {
+	ns := s.clone()
+	ns.limiter = limiter
+	return ns
+}
+
+// Wait 获取 method path 对应接口的一个配额
+func (l *RateLimiter) Wait(ctx context.Context, method string, path string) error {
+	if l == nil {
+		return nil
+	}
+
+	template, ok := l.match(method, path)
+	if !ok {
+		return nil
+	}
+
+	l.mu.Lock()
+	b := l.buckets[template]
+	wait, ok := b.reserve(time.Now(), l.mode == RateLimitWait)
+	l.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRateLimited, template)
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		l.cancel(b)
+		return fmt.Errorf("%w: %s, wait %s exceeds context deadline", ErrRateLimited, template, wait)
+	}
+
+	if err := sleep(ctx, wait); err != nil {
+		l.cancel(b)
+		return err
+	}
+
+	return nil
+}
+
+func (l *RateLimiter) cancel(b *bucket) {
+	l.mu.Lock()
+	b.tokens++
+	l.mu.Unlock()
+}
+
+// match 返回匹配 method path 的接口模板，有多个匹配时使用固定段最多的模板
+func (l *RateLimiter) match(method string, path string) (string, bool) {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var (
+		best      string
+		bestScore = -1
+	)
+	for _, e := range l.endpoints {
+		score, ok := e.match(method, segments)
+		if ok && score > bestScore {
+			best, bestScore = e.template, score
+		}
+	}
+
+	return best, bestScore >= 0
+}
+
+type endpoint struct {
+	template string
+	// 为空时匹配所有 METHOD
+	method   string
+	segments []string
+}
+
+// match 返回匹配程度：固定段的数量，指定 METHOD 时额外加 1
+func (e *endpoint) match(method string, segments []string) (int, bool) {
+	if e.method != "" && e.method != method {
+		return 0, false
+	}
+
+	if len(segments) != len(e.segments) {
+		return 0, false
+	}
+
+	score := 0
+	for i, v := range e.segments {
+		if strings.HasPrefix(v, "{") && strings.HasSuffix(v, "}") {
+			continue
+		}
+		if v != segments[i] {
+			return 0, false
+		}
+		score += 2
+	}
+
+	if e.method != "" {
+		score++
+	}
+	return score, true
+}
+
+// bucket 令牌桶，调用方需持有 RateLimiter.mu
+type bucket struct {
+	tokens float64
+	burst  float64
+	// 每秒生成的令牌数
+	rate float64
+	last time.Time
+}
+
+// reserve 取出一个令牌，返回需要等待的时间
+// allowDebt 为 false 时，没有可用令牌则不取出并返回 false
+func (b *bucket) reserve(now time.Time, allowDebt bool) (time.Duration, bool) {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	if !allowDebt {
+		return 0, false
+	}
+
+	b.tokens--
+	return time.Duration(-b.tokens / b.rate * float64(time.Second)), true
+}
@@ -0,0 +1,65 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestService_RateLimiter(t *testing.T) {
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}).RateLimiter(NewRateLimiter(map[string]Quota{
+		EndpointGetTestNotificationStatus: {Limit: 2, Per: time.Hour},
+	}, RateLimitFailFast))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		// Sandbox 返回的 Service 共享限流状态
+		if _, err := service.Sandbox(false).GetTestNotificationStatus(ctx, "token"); err != nil {
+			t.Errorf("TestService_RateLimiter failed. err:%v", err)
+			return
+		}
+	}
+
+	if _, err := service.Sandbox(true).GetTestNotificationStatus(ctx, "other"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("TestService_RateLimiter got err:%v, want ErrRateLimited", err)
+	}
+
+	// 其他接口不受影响
+	if _, err := service.RequestTestNotification(ctx); err != nil {
+		t.Errorf("TestService_RateLimiter RequestTestNotification failed. err:%v", err)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	l := NewRateLimiter(map[string]Quota{
+		"/inApps/v1/messaging/image/{imageIdentifier}": {Limit: 1, Per: 50 * time.Millisecond},
+		EndpointGetImageList:                           {Limit: 1, Per: time.Hour},
+	}, RateLimitWait)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx, "PUT", "/inApps/v1/messaging/image/abc"); err != nil {
+			t.Errorf("TestRateLimiter_Wait failed. err:%v", err)
+			return
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("TestRateLimiter_Wait got elapsed:%s, want >= 100ms", elapsed)
+	}
+
+	// image/list 匹配固定段更多的 EndpointGetImageList
+	if err := l.Wait(ctx, "GET", "/inApps/v1/messaging/image/list"); err != nil {
+		t.Errorf("TestRateLimiter_Wait image list failed. err:%v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "GET", "/inApps/v1/messaging/image/list"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("TestRateLimiter_Wait got err:%v, want ErrRateLimited", err)
+	}
+}
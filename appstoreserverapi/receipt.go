@@ -0,0 +1,72 @@
+package appstoreserverapi
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/beanscc/appstore/internal/receiptasn1"
+	"github.com/beanscc/appstore/pkcs7"
+)
+
+// ErrReceiptNoTransaction 收据中没有内购交易
+var ErrReceiptNoTransaction = errors.New("appstore.appstoreserverapi: receipt contains no in-app purchase")
+
+// ExtractTransactionIDFromAppReceipt 从 base64 编码的 app 收据中获取一个交易 ID，用于 GetTransactionHistoryReq.TransactionID，
+// 从收据迁移到 App Store Server API
+// 不验证收据签名，返回的交易 ID 须通过 GetTransactionHistory 等接口查询，由 App Store 返回已签名的交易信息
+// 收据中没有内购交易时返回 ErrReceiptNoTransaction
+// https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func ExtractTransactionIDFromAppReceipt(appReceipt string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(appReceipt)
+	if err != nil {
+		return "", fmt.Errorf("appstore.appstoreserverapi: decode receipt: %w", err)
+	}
+
+	sd, err := pkcs7.Parse(data)
+	if err != nil {
+		return "", err
+	}
+
+	attrs, err := parseReceiptAttributes(sd.Content)
+	if err != nil {
+		return "", err
+	}
+
+	for _, attr := range attrs {
+		if attr.Type != receiptasn1.FieldInApp {
+			continue
+		}
+
+		inApp, err := parseReceiptAttributes(attr.Value)
+		if err != nil {
+			return "", err
+		}
+
+		for _, v := range inApp {
+			if v.Type != receiptasn1.FieldTransactionID {
+				continue
+			}
+
+			var transactionID string
+			if _, err := asn1.Unmarshal(v.Value, &transactionID); err != nil {
+				return "", fmt.Errorf("appstore.appstoreserverapi: parse receipt transaction id: %w", err)
+			}
+			if transactionID != "" {
+				return transactionID, nil
+			}
+		}
+	}
+
+	return "", ErrReceiptNoTransaction
+}
+
+func parseReceiptAttributes(payload []byte) ([]receiptasn1.Attribute, error) {
+	attrs, err := receiptasn1.ParseAttributes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("appstore.appstoreserverapi: %w", err)
+	}
+
+	return attrs, nil
+}
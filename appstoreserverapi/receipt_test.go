@@ -0,0 +1,55 @@
+package appstoreserverapi
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/beanscc/appstore/internal/receiptasn1"
+	"github.com/beanscc/appstore/pkcs7/pkcs7test"
+)
+
+func testReceipt(t *testing.T, signer *pkcs7test.Signer, inApps ...string) string {
+	marshal := func(attrs []receiptasn1.Attribute) []byte {
+		out, err := asn1.MarshalWithParams(attrs, "set")
+		if err != nil {
+			t.Fatalf("asn1.Marshal failed. err:%v", err)
+		}
+		return out
+	}
+
+	bundleID, _ := asn1.MarshalWithParams("com.example", "utf8")
+	attrs := []receiptasn1.Attribute{{Type: receiptasn1.FieldBundleID, Version: 1, Value: bundleID}}
+	for _, transactionID := range inApps {
+		value, _ := asn1.MarshalWithParams(transactionID, "utf8")
+		inApp := marshal([]receiptasn1.Attribute{{Type: receiptasn1.FieldTransactionID, Version: 1, Value: value}})
+		attrs = append(attrs, receiptasn1.Attribute{Type: receiptasn1.FieldInApp, Version: 1, Value: inApp})
+	}
+
+	data, err := signer.Sign(marshal(attrs))
+	if err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestExtractTransactionIDFromAppReceipt(t *testing.T) {
+	signer, err := pkcs7test.NewSigner()
+	if err != nil {
+		t.Fatalf("pkcs7test.NewSigner failed. err:%v", err)
+	}
+
+	got, err := ExtractTransactionIDFromAppReceipt(testReceipt(t, signer, "1000", "2000"))
+	if err != nil || got != "1000" {
+		t.Errorf("TestExtractTransactionIDFromAppReceipt got:%s, err:%v", got, err)
+	}
+
+	if _, err := ExtractTransactionIDFromAppReceipt(testReceipt(t, signer)); !errors.Is(err, ErrReceiptNoTransaction) {
+		t.Errorf("TestExtractTransactionIDFromAppReceipt got err:%v, want ErrReceiptNoTransaction", err)
+	}
+
+	if _, err := ExtractTransactionIDFromAppReceipt("not base64!"); err == nil {
+		t.Errorf("TestExtractTransactionIDFromAppReceipt: invalid receipt should be rejected")
+	}
+}
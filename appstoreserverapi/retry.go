@@ -0,0 +1,207 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// DefaultRetryableCodes 默认重试的 ApiError.Code
+var DefaultRetryableCodes = []int{
+	ErrCodeAccountNotFoundRetryable,
+	ErrCodeAppNotFoundRetryable,
+	ErrCodeOriginalTransactionIDNotFoundRetryable,
+	ErrCodeGeneralInternalRetryable,
+	ErrCodeRateLimitExceeded,
+}
+
+// RetryPolicy 请求失败时的重试策略
+// 以下情况会重试：超时、连接被重置或拒绝等临时的网络错误、HTTP 429 及 5xx、ApiError.Code 属于 RetryableCodes
+// 等待时间超过 ctx 的 deadline 时不再重试
+type RetryPolicy struct {
+	// 最大请求次数（包含第一次请求），小于等于 1 时不重试
+	MaxAttempts int
+	// 第一次重试前的等待时间，之后每次翻倍，默认 500ms
+	InitialBackoff time.Duration
+	// 最大等待时间，默认 30s，响应的 Retry-After 超过该时间时按该时间等待
+	MaxBackoff time.Duration
+	// 等待时间的随机抖动比例，取值 [0, 1]，实际等待时间在 [backoff*(1-Jitter), backoff] 之间
+	Jitter float64
+	// 是否重试非 GET 请求，默认只重试幂等的 GET 请求
+	RetryNonIdempotent bool
+	// 重试的 ApiError.Code，为 nil 时使用 DefaultRetryableCodes
+	RetryableCodes []int
+	// 每次重试前调用，可用于记录日志
+	OnRetry func(ctx context.Context, event RetryEvent)
+}
+
+// RetryEvent 一次重试的信息
+type RetryEvent struct {
+	Method string
+	Path   string
+	// 失败的请求次数，从 1 开始
+	Attempt int
+	// 失败请求的 HTTP 状态码，网络错误时为 0
+	StatusCode int
+	// 重试前的等待时间，响应包含 Retry-After 时使用该时间
+	Wait time.Duration
+	Err  error
+}
+
+// DefaultRetryPolicy 最多请求 3 次，等待时间从 500ms 开始翻倍，20% 随机抖动
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+// next 判断第 attempt 次请求失败后是否重试，返回重试前的等待时间
+func (p *RetryPolicy) next(ctx context.Context, method string, attempt int, statusCode int, header http.Header, err error) (time.Duration, bool) {
+	if p == nil || err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	if method != http.MethodGet && !p.RetryNonIdempotent {
+		return 0, false
+	}
+
+	if !p.retryable(statusCode, err) {
+		return 0, false
+	}
+
+	wait, ok := parseRetryAfter(header.Get("Retry-After"), time.Now())
+	if !ok {
+		wait = p.backoff(attempt)
+	} else if limit := p.maxBackoff(); wait > limit {
+		wait = limit
+	}
+
+	// 等待结束前 ctx 已超时，不再重试
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+func (p *RetryPolicy) retryable(statusCode int, err error) bool {
+	// 仅重试超时、连接被重置或拒绝等临时的网络错误；
+	// 证书验证失败、不支持的协议及获取 token 失败等错误重试也不会成功
+	if statusCode == 0 {
+		if errors.Is(err, context.Canceled) {
+			return false
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	if statusCode == http.StatusTooManyRequests || statusCode >= 500 {
+		return true
+	}
+
+	codes := p.RetryableCodes
+	if codes == nil {
+		codes = DefaultRetryableCodes
+	}
+
+	if apiErr, ok := ApiErrorFromError(err); ok && apiErr != nil {
+		for _, code := range codes {
+			if apiErr.Code == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// backoff 第 attempt 次请求失败后的等待时间
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	wait, limit := p.InitialBackoff, p.maxBackoff()
+	if wait <= 0 {
+		wait = 500 * time.Millisecond
+	}
+
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+
+	if p.Jitter > 0 {
+		jitter := int64(float64(wait) * p.Jitter)
+		if jitter > 0 {
+			wait -= time.Duration(rand.Int63n(jitter + 1))
+		}
+	}
+
+	return wait
+}
+
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return p.MaxBackoff
+}
+
+func (p *RetryPolicy) onRetry(ctx context.Context, event RetryEvent) {
+	if p != nil && p.OnRetry != nil {
+		p.OnRetry(ctx, event)
+	}
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数及 HTTP 日期
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	if wait := t.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// sleep 等待 d，ctx 结束时返回 ctx.Err()
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestService_Retry(t *testing.T) {
+	var (
+		attempts int
+		events   []RetryEvent
+	)
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case r.Method != "GET":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorCode":5000001,"errorMessage":"An unknown error occurred. Please try again."}`))
+		case attempts == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`<html>unavailable</html>`))
+		case attempts == 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errorCode":4290000,"errorMessage":"Rate limit exceeded."}`))
+		case attempts == 3:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":4040002,"errorMessage":"Account not found. Please try again."}`))
+		default:
+			w.Write([]byte(`{"testNotificationToken":"token"}`))
+		}
+	}).Retry(&RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Millisecond,
+		OnRetry: func(ctx context.Context, event RetryEvent) {
+			events = append(events, event)
+		},
+	})
+
+	if _, err := service.GetTestNotificationStatus(context.Background(), "token"); err != nil {
+		t.Errorf("TestService_Retry failed. err:%v", err)
+		return
+	}
+
+	if attempts != 4 || len(events) != 3 {
+		t.Errorf("TestService_Retry got attempts:%d, events:%#v", attempts, events)
+		return
+	}
+
+	if events[0].StatusCode != http.StatusServiceUnavailable || events[1].StatusCode != http.StatusTooManyRequests ||
+		events[1].Wait != 0 || events[2].Attempt != 3 {
+		t.Errorf("TestService_Retry got events:%#v", events)
+	}
+
+	// 默认不重试 POST
+	attempts = 0
+	_, err := service.RequestTestNotification(context.Background())
+	if apiErr, ok := ApiErrorFromError(err); !ok || apiErr.Code != ErrCodeGeneralInternalRetryable || attempts != 1 {
+		t.Errorf("TestService_Retry POST got attempts:%d, err:%v", attempts, err)
+	}
+}
+
+func TestService_Retry_exhausted(t *testing.T) {
+	var attempts int
+	service := testService(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+	}).Retry(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	// 不可重试的错误
+	if _, err := service.GetTestNotificationStatus(context.Background(), "token"); err == nil || attempts != 1 {
+		t.Errorf("TestService_Retry_exhausted got attempts:%d, err:%v", attempts, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.GetTestNotificationStatus(ctx, "token"); !errors.Is(err, context.Canceled) {
+		t.Errorf("TestService_Retry_exhausted got err:%v, want context.Canceled", err)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 5: time.Second} {
+		if got := p.backoff(attempt); got > max || got < max/2 {
+			t.Errorf("TestRetryPolicy_backoff attempt:%d got:%s, want [%s, %s]", attempt, got, max/2, max)
+		}
+	}
+
+	now := time.Now()
+	if got, ok := parseRetryAfter(now.Add(3*time.Second).UTC().Format(http.TimeFormat), now); !ok || got < 2*time.Second || got > 3*time.Second {
+		t.Errorf("TestRetryPolicy_backoff parseRetryAfter got:%s, ok:%v", got, ok)
+	}
+}
+
+func TestRetryPolicy_next(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second}
+	ctx := context.Background()
+
+	// 获取 token 失败等非网络错误不重试
+	if _, ok := p.next(ctx, "GET", 1, 0, nil, errors.New("appstore.appstoreserverapi: invalid private key")); ok {
+		t.Errorf("TestRetryPolicy_next: non-network error should not be retried")
+	}
+
+	if _, ok := p.next(ctx, "GET", 1, 0, nil, &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}); !ok {
+		t.Errorf("TestRetryPolicy_next: network error should be retried")
+	}
+
+	timeout := &url.Error{Op: "Get", URL: "https://api.storekit.itunes.apple.com", Err: &net.DNSError{IsTimeout: true}}
+	if _, ok := p.next(ctx, "GET", 1, 0, nil, timeout); !ok {
+		t.Errorf("TestRetryPolicy_next: timeout should be retried")
+	}
+
+	// *url.Error 实现了 net.Error，但证书验证失败及不支持的协议不重试
+	permanent := []error{
+		&url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme \"ftp\"")},
+		&url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}},
+	}
+	for _, err := range permanent {
+		if _, ok := p.next(ctx, "GET", 1, 0, nil, err); ok {
+			t.Errorf("TestRetryPolicy_next: %v should not be retried", err)
+		}
+	}
+
+	// Retry-After 不超过 MaxBackoff
+	header := http.Header{"Retry-After": []string{"3600"}}
+	if wait, ok := p.next(ctx, "GET", 1, http.StatusTooManyRequests, header, &ApiError{Code: ErrCodeRateLimitExceeded}); !ok || wait != time.Second {
+		t.Errorf("TestRetryPolicy_next got wait:%s, ok:%v", wait, ok)
+	}
+
+	// 等待时间超过 ctx 的 deadline
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, ok := p.next(ctx, "GET", 1, http.StatusTooManyRequests, header, &ApiError{Code: ErrCodeRateLimitExceeded}); ok {
+		t.Errorf("TestRetryPolicy_next: wait beyond ctx deadline should not be retried")
+	}
+}
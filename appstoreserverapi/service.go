@@ -1,35 +1,129 @@
 package appstoreserverapi
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/beanscc/appstore/jws"
 )
 
 // Service app store server api 实现
 // api: https://developer.apple.com/documentation/appstoreserverapi/generating_tokens_for_api_requests
 type Service struct {
 	client *http.Client
-	// debug 将打印请求日志，用于开发测试
+	// debug 记录包含请求及响应 body 的日志，用于开发测试
 	debug bool
+	// 请求日志，参见 WithLogger
+	logger *slog.Logger
 
 	// 是否 sandbox 环境
 	sandbox bool
 	// api token
 	token *Token
+	// 验证响应中的 JWS，为 nil 时使用 jws.DefaultVerifier
+	verifier *jws.Verifier
+	// 请求失败时的重试策略，为 nil 时不重试
+	retry *RetryPolicy
+	// 客户端限流，clone 时共享，为 nil 时不限流
+	limiter *RateLimiter
+	// 生产环境查询交易返回 TransactionIdNotFound 时，是否查询 sandbox 环境
+	sandboxFallback bool
+	// 已延长续订日期的历史记录，参见 ExtensionHistory
+	extensionHistory ExtensionHistoryFunc
+
+	// 请求地址，为空时使用 Apple 的地址，参见 Host
+	baseURL        string
+	sandboxBaseURL string
+	// 请求的 User-Agent，为空时使用 net/http 的默认值
+	userAgent string
+	// 包装 client.Transport 的中间件
+	middlewares []Middleware
 }
 
-func NewService(token *Token) *Service {
-	return &Service{
+// Option NewService 的配置项
+type Option func(s *Service)
+
+// Middleware 包装请求使用的 http.RoundTripper，可用于添加 header、记录请求等
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc 将函数转换为 http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithHTTPClient 设置请求使用的 http.Client，默认为 http.DefaultClient
+// 请求超时由 Config.Timeout 控制
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		if client != nil {
+			s.client = client
+		}
+	}
+}
+
+// WithBaseURL 设置请求地址，如代理或本地 mock 服务的地址，未设置 WithSandboxBaseURL 时 sandbox 环境也使用该地址
+func WithBaseURL(baseURL string) Option {
+	return func(s *Service) {
+		s.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithSandboxBaseURL 设置 sandbox 环境的请求地址
+func WithSandboxBaseURL(baseURL string) Option {
+	return func(s *Service) {
+		s.sandboxBaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithUserAgent 设置请求的 User-Agent
+func WithUserAgent(userAgent string) Option {
+	return func(s *Service) {
+		s.userAgent = userAgent
+	}
+}
+
+// WithRoundTripper 添加包装 http.Client.Transport 的中间件，可多次使用，
+// 先添加的中间件在外层，最先处理请求
+func WithRoundTripper(middlewares ...Middleware) Option {
+	return func(s *Service) {
+		s.middlewares = append(s.middlewares, middlewares...)
+	}
+}
+
+func NewService(token *Token, opts ...Option) *Service {
+	s := &Service{
 		client: http.DefaultClient,
 		token:  token,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if len(s.middlewares) > 0 {
+		transport := s.client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		for i := len(s.middlewares) - 1; i >= 0; i-- {
+			transport = s.middlewares[i](transport)
+		}
+
+		client := *s.client
+		client.Transport = transport
+		s.client = &client
+	}
+
+	return s
 }
 
 func (s *Service) clone() *Service {
@@ -39,6 +133,7 @@ func (s *Service) clone() *Service {
 	return ns
 }
 
+// Debug 开启后以 Info 级别记录请求日志，并包含脱敏后的请求及响应 body，没有设置 WithLogger 时使用 slog.Default()
 func (s *Service) Debug(debug bool) *Service {
 	ns := s.clone()
 	ns.debug = debug
@@ -51,10 +146,57 @@ func (s *Service) Sandbox(sandbox bool) *Service {
 	return ns
 }
 
+// Verifier 设置验证响应中 JWS 使用的 jws.Verifier
+func (s *Service) Verifier(verifier *jws.Verifier) *Service {
+	ns := s.clone()
+	ns.verifier = verifier
+	return ns
+}
+
+// Retry 设置请求失败时的重试策略，为 nil 时不重试
+func (s *Service) Retry(policy *RetryPolicy) *Service {
+	ns := s.clone()
+	ns.retry = policy
+	return ns
+}
+
+// SandboxFallback 开启后，生产环境的 GetTransactionInfo、GetTransactionHistory、GetRefundHistory
+// 返回 TransactionIdNotFound (4040010) 时，使用 Sandbox(true) 返回的 Service 重新查询，
+// 用于处理无法确定环境的客户端交易 ID
+func (s *Service) SandboxFallback(fallback bool) *Service {
+	ns := s.clone()
+	ns.sandboxFallback = fallback
+	return ns
+}
+
+// Environment 返回当前请求的环境
+func (s *Service) Environment() Environment {
+	if s.sandbox {
+		return EnvironmentSandbox
+	}
+	return EnvironmentProduction
+}
+
+// SignedDataVerifier 返回验证 JWS 的 SignedDataVerifier，payload 须属于 Config.BundleID 及当前环境
+func (s *Service) SignedDataVerifier() *SignedDataVerifier {
+	return NewSignedDataVerifier(s.token.conf.BundleID, s.token.conf.AppAppleID, s.Environment(), s.verifier)
+}
+
+// Host 返回当前环境的请求地址，可通过 WithBaseURL、WithSandboxBaseURL 设置
 func (s *Service) Host() string {
 	if s.sandbox {
+		if s.sandboxBaseURL != "" {
+			return s.sandboxBaseURL
+		}
+		if s.baseURL != "" {
+			return s.baseURL
+		}
 		return "https://api.storekit-sandbox.itunes.apple.com"
 	}
+
+	if s.baseURL != "" {
+		return s.baseURL
+	}
 	return "https://api.storekit.itunes.apple.com"
 }
 
@@ -68,10 +210,93 @@ func (s *Service) get(ctx context.Context, path string, query url.Values) (int,
 	return s.request(ctx, "GET", u.String(), nil)
 }
 
+// getWithFallback 同 get，开启 SandboxFallback 时，生产环境返回 TransactionIdNotFound 则请求 sandbox 环境
+// 返回实际响应请求的 Service
+func (s *Service) getWithFallback(ctx context.Context, path string, query url.Values) (*Service, []byte, error) {
+	_, body, err := s.get(ctx, path, query)
+	if err == nil || !s.sandboxFallback || s.sandbox {
+		return s, body, err
+	}
+
+	if apiErr, ok := ApiErrorFromError(err); !ok || apiErr == nil || apiErr.Code != ErrCodeTransactionIDNotFound {
+		return s, body, err
+	}
+
+	sandbox := s.Sandbox(true)
+	_, body, err = sandbox.get(ctx, path, query)
+	return sandbox, body, err
+}
+
+func (s *Service) requestJSON(ctx context.Context, method string, path string, in interface{}) (int, []byte, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return s.request(ctx, method, path, bytes.NewReader(payload))
+}
+
 func (s *Service) request(ctx context.Context, method string, path string, body io.Reader) (int, []byte, error) {
+	var contentType string
+	if body != nil {
+		contentType = "application/json"
+	}
+
+	return s.requestWithContentType(ctx, method, path, contentType, body)
+}
+
+func (s *Service) requestWithContentType(ctx context.Context, method string, path string, contentType string, body io.Reader) (int, []byte, error) {
+	// 读取 body 以便重试时重新发送
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(body); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	// 仅在记录日志时生成 request_id
+	var requestID string
+	if s.requestLogger() != nil {
+		requestID = requestIDFromContext(ctx)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := s.limiter.Wait(ctx, method, path); err != nil {
+			return 0, nil, err
+		}
+
+		start := time.Now()
+		statusCode, header, payload, err := s.do(ctx, method, path, contentType, reqBody)
+		s.logRequest(ctx, &requestLog{
+			RequestID:   requestID,
+			Method:      method,
+			Path:        path,
+			Attempt:     attempt,
+			ContentType: contentType,
+			Body:        reqBody,
+			StatusCode:  statusCode,
+			Latency:     time.Since(start),
+			Response:    payload,
+			Err:         err,
+		})
+
+		wait, retry := s.retry.next(ctx, method, attempt, statusCode, header, err)
+		if !retry {
+			return statusCode, payload, err
+		}
+
+		s.retry.onRetry(ctx, RetryEvent{Method: method, Path: path, Attempt: attempt, StatusCode: statusCode, Wait: wait, Err: err})
+		if err := sleep(ctx, wait); err != nil {
+			return statusCode, payload, err
+		}
+	}
+}
+
+// do 发送一次请求，请求超时为 Config.Timeout
+func (s *Service) do(ctx context.Context, method string, path string, contentType string, body []byte) (int, http.Header, []byte, error) {
 	token, err := s.token.Get()
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 
 	timeout := s.token.conf.Timeout
@@ -80,36 +305,36 @@ func (s *Service) request(ctx context.Context, method string, path string, body
 	}
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
 	req, err := http.NewRequestWithContext(ctx, method,
-		fmt.Sprintf("%s/%s", s.Host(), strings.TrimPrefix(path, "/")), body)
+		fmt.Sprintf("%s/%s", s.Host(), strings.TrimPrefix(path, "/")), reqBody)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", token))
-
-	if s.debug {
-		reqLog, _ := httputil.DumpRequestOut(req, true)
-		log.Printf("[debug] %s", reqLog)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.userAgent != "" {
+		req.Header.Set("User-Agent", s.userAgent)
 	}
 
-	start := time.Now()
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
 
-	if s.debug {
-		respLog, _ := httputil.DumpResponse(resp, true)
-		log.Printf("[debug] [latency:%s] %s", time.Now().Sub(start), respLog)
-	}
-
 	payload, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, nil, err
+		return 0, nil, nil, err
 	}
 
 	// 响应错误
 	err = handleApiErr(resp.StatusCode, payload)
-	return resp.StatusCode, payload, err
+	return resp.StatusCode, resp.Header, payload, err
 }
@@ -0,0 +1,108 @@
+package appstoreserverapi
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rewriteTransport 将请求转发到本地测试服务
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// testService 返回请求 handler 的 Service，使用随机生成的 API 私钥
+func testService(t *testing.T, handler http.HandlerFunc) *Service {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey failed. err:%v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalPKCS8PrivateKey failed. err:%v", err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	service := NewService(NewToken(&Config{
+		BundleID:   "com.example",
+		Issuer:     "issuer",
+		KeyID:      "key-id",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		Timeout:    5 * time.Second,
+	}))
+	service.client = &http.Client{Transport: &rewriteTransport{target: target}}
+
+	return service
+}
+
+func TestNewService_Options(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"testNotificationToken":"token"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				req = req.Clone(req.Context())
+				req.Header.Add("X-Middleware", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	base := testService(t, nil)
+	service := NewService(base.token,
+		WithHTTPClient(&http.Client{}),
+		WithBaseURL(srv.URL+"/"),
+		WithUserAgent("appstore-test"),
+		WithRoundTripper(middleware("a"), middleware("b")),
+	)
+
+	if service.Host() != srv.URL || service.Sandbox(true).Host() != srv.URL {
+		t.Errorf("TestNewService_Options got host:%s, sandbox host:%s", service.Host(), service.Sandbox(true).Host())
+		return
+	}
+
+	if _, err := service.GetTestNotificationStatus(context.Background(), "token"); err != nil {
+		t.Errorf("TestNewService_Options failed. err:%v", err)
+		return
+	}
+
+	if got.UserAgent() != "appstore-test" || len(order) != 2 || order[0] != "a" || order[1] != "b" ||
+		len(got.Header.Values("X-Middleware")) != 2 {
+		t.Errorf("TestNewService_Options got user agent:%s, order:%v, header:%v", got.UserAgent(), order, got.Header)
+	}
+
+	sandbox := NewService(base.token, WithBaseURL("http://proxy"), WithSandboxBaseURL("http://sandbox-proxy")).Sandbox(true)
+	if sandbox.Host() != "http://sandbox-proxy" {
+		t.Errorf("TestNewService_Options got sandbox host:%s", sandbox.Host())
+	}
+
+	if NewService(base.token).Host() != "https://api.storekit.itunes.apple.com" {
+		t.Errorf("TestNewService_Options got default host:%s", NewService(base.token).Host())
+	}
+}
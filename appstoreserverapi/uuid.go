@@ -0,0 +1,39 @@
+package appstoreserverapi
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// isUUID 判断 s 是否为 8-4-4-4-12 格式的 UUID 字符串
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newUUID 生成随机 (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,196 @@
+package appstoreserverapi
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/beanscc/appstore/jws"
+)
+
+// VerificationStatus payload 断言失败的原因
+type VerificationStatus int
+
+const (
+	// VerificationStatusInvalidAppIdentifier bundleId 或 appAppleId 不匹配
+	VerificationStatusInvalidAppIdentifier VerificationStatus = iota + 1
+	// VerificationStatusInvalidEnvironment environment 不匹配
+	VerificationStatusInvalidEnvironment
+)
+
+func (s VerificationStatus) String() string {
+	switch s {
+	case VerificationStatusInvalidAppIdentifier:
+		return "INVALID_APP_IDENTIFIER"
+	case VerificationStatusInvalidEnvironment:
+		return "INVALID_ENVIRONMENT"
+	}
+	return "VerificationStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// VerificationError 签名验证通过，但 payload 不属于当前 app 或环境
+type VerificationError struct {
+	Status   VerificationStatus
+	Field    string
+	Expected string
+	Actual   string
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("appstore.appstoreserverapi.SignedDataVerifier: %s, %s expected:%q, actual:%q",
+		e.Status, e.Field, e.Expected, e.Actual)
+}
+
+// SignedDataVerifier 验证 JWS 签名，并断言 payload 的 bundleId、appAppleId 及 environment
+//   - bundleID 为空时不检查 bundleId，否则 payload 缺少 bundleId 视为不匹配
+//   - appAppleID 为 0 时不检查 appAppleId（仅通知及 app transaction 包含该字段），
+//     否则 production 环境的 payload 缺少 appAppleId 视为不匹配，sandbox 环境不包含该字段
+//   - environment 为空时不检查 environment
+type SignedDataVerifier struct {
+	// 为 nil 时使用 jws.DefaultVerifier
+	verifier    *jws.Verifier
+	bundleID    string
+	appAppleID  int64
+	environment Environment
+}
+
+func NewSignedDataVerifier(bundleID string, appAppleID int64, environment Environment, verifier *jws.Verifier) *SignedDataVerifier {
+	return &SignedDataVerifier{
+		verifier:    verifier,
+		bundleID:    bundleID,
+		appAppleID:  appAppleID,
+		environment: environment,
+	}
+}
+
+func (v *SignedDataVerifier) jwsVerifier() *jws.Verifier {
+	if v.verifier == nil {
+		return jws.DefaultVerifier()
+	}
+	return v.verifier
+}
+
+// Transaction 验证并解码交易信息
+func (v *SignedDataVerifier) Transaction(s JWSTransaction) (*Transaction, error) {
+	out, err := s.Verify(v.jwsVerifier())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.checkBundleID(out.BundleID); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(out.Environment); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Transactions 依次验证并解码交易信息，任意一个失败时返回 error
+func (v *SignedDataVerifier) Transactions(ts JWSTransactions) ([]Transaction, error) {
+	transactions := make([]Transaction, 0, len(ts))
+	for _, s := range ts {
+		transaction, err := v.Transaction(s)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *transaction)
+	}
+	return transactions, nil
+}
+
+// RenewalInfo 验证并解码续订信息，续订信息不包含 bundleId，仅检查 environment
+func (v *SignedDataVerifier) RenewalInfo(s JWSRenewalInfo) (*RenewalInfo, error) {
+	out, err := s.Verify(v.jwsVerifier())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.checkEnvironment(out.Environment); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// AppTransaction 验证并解码 app transaction，使用 receiptType 断言 environment
+func (v *SignedDataVerifier) AppTransaction(s JWSAppTransaction) (*AppTransaction, error) {
+	out, err := s.Verify(v.jwsVerifier())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.check(out.BundleID, out.AppAppleID, out.ReceiptType); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Notification 验证并解码通知，使用 data、summary 或 externalPurchaseToken 中的 bundleId、appAppleId 及 environment 断言
+// 不检查通知中嵌套的 signedTransactionInfo 和 signedRenewalInfo
+// 三者均不包含时，配置了任一断言字段即返回 VerificationError
+func (v *SignedDataVerifier) Notification(s JWSNotification) (*NotificationV2, error) {
+	out, err := s.Verify(v.jwsVerifier())
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case out.Summary.RequestIdentifier != "":
+		err = v.check(out.Summary.BundleID, out.Summary.AppAppleID, out.Summary.Environment)
+	case out.Data != (NotificationV2Data{}):
+		err = v.check(out.Data.BundleID, out.Data.AppAppleID, out.Data.Environment)
+	case out.ExternalPurchaseToken != (NotificationV2ExternalPurchaseToken{}):
+		token := out.ExternalPurchaseToken
+		err = v.check(token.BundleID, token.AppAppleID, token.Environment())
+	default:
+		err = v.check("", 0, "")
+		if err == nil && v.appAppleID != 0 {
+			err = &VerificationError{Status: VerificationStatusInvalidAppIdentifier, Field: "appAppleId",
+				Expected: strconv.FormatInt(v.appAppleID, 10), Actual: "0"}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// check 断言 bundleId、appAppleId 及 environment
+func (v *SignedDataVerifier) check(bundleID string, appAppleID int64, environment Environment) error {
+	if err := v.checkBundleID(bundleID); err != nil {
+		return err
+	}
+	if err := v.checkAppAppleID(appAppleID, environment); err != nil {
+		return err
+	}
+	return v.checkEnvironment(environment)
+}
+
+// checkBundleID bundleID 为空表示 payload 缺少该字段，视为不匹配
+func (v *SignedDataVerifier) checkBundleID(bundleID string) error {
+	if v.bundleID != "" && bundleID != v.bundleID {
+		return &VerificationError{Status: VerificationStatusInvalidAppIdentifier, Field: "bundleId", Expected: v.bundleID, Actual: bundleID}
+	}
+	return nil
+}
+
+// checkAppAppleID sandbox 环境的 payload 不包含 appAppleId，production 环境缺少时视为不匹配
+func (v *SignedDataVerifier) checkAppAppleID(appAppleID int64, environment Environment) error {
+	if v.appAppleID != 0 && (appAppleID != 0 || environment == EnvironmentProduction) && appAppleID != v.appAppleID {
+		return &VerificationError{Status: VerificationStatusInvalidAppIdentifier, Field: "appAppleId",
+			Expected: strconv.FormatInt(v.appAppleID, 10), Actual: strconv.FormatInt(appAppleID, 10)}
+	}
+	return nil
+}
+
+func (v *SignedDataVerifier) checkEnvironment(environment Environment) error {
+	if v.environment != "" && environment != v.environment {
+		return &VerificationError{Status: VerificationStatusInvalidEnvironment, Field: "environment",
+			Expected: string(v.environment), Actual: string(environment)}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package appstoreserverapi
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/beanscc/appstore/jws"
+	"github.com/beanscc/appstore/jws/jwstest"
+)
+
+func testSigner(t *testing.T) (*jwstest.Signer, *jws.Verifier) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	return signer, jws.NewVerifier(jws.WithRoots(signer.Roots()))
+}
+
+func testSign(t *testing.T, signer *jwstest.Signer, payload interface{}) string {
+	token, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+	return token
+}
+
+func TestSignedDataVerifier_Transaction(t *testing.T) {
+	signer, verifier := testSigner(t)
+	v := NewSignedDataVerifier("com.example", 0, EnvironmentProduction, verifier)
+
+	tests := []struct {
+		name   string
+		tx     Transaction
+		status VerificationStatus
+	}{
+		{name: "ok", tx: Transaction{TransactionID: "1", BundleID: "com.example", Environment: EnvironmentProduction}},
+		{name: "bundle id", tx: Transaction{TransactionID: "2", BundleID: "com.other", Environment: EnvironmentProduction}, status: VerificationStatusInvalidAppIdentifier},
+		{name: "environment", tx: Transaction{TransactionID: "3", BundleID: "com.example", Environment: EnvironmentSandbox}, status: VerificationStatusInvalidEnvironment},
+		{name: "missing bundle id", tx: Transaction{TransactionID: "4", Environment: EnvironmentProduction}, status: VerificationStatusInvalidAppIdentifier},
+	}
+
+	for _, tt := range tests {
+		got, err := v.Transaction(JWSTransaction(testSign(t, signer, tt.tx)))
+		if tt.status == 0 {
+			if err != nil || got.TransactionID != tt.tx.TransactionID {
+				t.Errorf("TestSignedDataVerifier_Transaction %s: got:%#v, err:%v", tt.name, got, err)
+			}
+			continue
+		}
+
+		var verr *VerificationError
+		if !errors.As(err, &verr) || verr.Status != tt.status {
+			t.Errorf("TestSignedDataVerifier_Transaction %s: got err:%v, want status:%s", tt.name, err, tt.status)
+		}
+	}
+}
+
+func TestSignedDataVerifier_Notification(t *testing.T) {
+	signer, verifier := testSigner(t)
+	v := NewSignedDataVerifier("com.example", 1234, EnvironmentSandbox, verifier)
+
+	n := NotificationV2{
+		NotificationType: NotificationV2TypeTest,
+		Data:             NotificationV2Data{AppAppleID: 1234, BundleID: "com.example", Environment: EnvironmentSandbox},
+	}
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); err != nil {
+		t.Errorf("TestSignedDataVerifier_Notification failed. err:%v", err)
+	}
+
+	n.Data.AppAppleID = 4321
+	var verr *VerificationError
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Field != "appAppleId" {
+		t.Errorf("TestSignedDataVerifier_Notification appAppleId got err:%v", err)
+	}
+
+	// sandbox 环境不包含 appAppleId
+	n.Data.AppAppleID = 0
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); err != nil {
+		t.Errorf("TestSignedDataVerifier_Notification sandbox without appAppleId failed. err:%v", err)
+	}
+
+	// production 环境缺少 appAppleId 视为不匹配
+	n.Data.Environment = EnvironmentProduction
+	production := NewSignedDataVerifier("com.example", 1234, EnvironmentProduction, verifier)
+	if _, err := production.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Field != "appAppleId" {
+		t.Errorf("TestSignedDataVerifier_Notification missing appAppleId got err:%v", err)
+	}
+
+	// externalPurchaseToken 通知使用 token 中的字段，sandbox 的 externalPurchaseId 以 SANDBOX 开头
+	n = NotificationV2{
+		NotificationType:      "EXTERNAL_PURCHASE_TOKEN",
+		ExternalPurchaseToken: NotificationV2ExternalPurchaseToken{ExternalPurchaseID: "SANDBOX_1", AppAppleID: 1234, BundleID: "com.example"},
+	}
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); err != nil {
+		t.Errorf("TestSignedDataVerifier_Notification externalPurchaseToken failed. err:%v", err)
+	}
+
+	n.ExternalPurchaseToken.BundleID = "com.other"
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Field != "bundleId" {
+		t.Errorf("TestSignedDataVerifier_Notification externalPurchaseToken bundleId got err:%v", err)
+	}
+
+	// data、summary 及 externalPurchaseToken 均不包含时无法断言，视为不匹配
+	n = NotificationV2{NotificationType: NotificationV2TypeTest, NotificationUUID: "uuid"}
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Status != VerificationStatusInvalidAppIdentifier {
+		t.Errorf("TestSignedDataVerifier_Notification without data got err:%v", err)
+	}
+
+	appOnly := NewSignedDataVerifier("", 1234, "", verifier)
+	if _, err := appOnly.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Field != "appAppleId" {
+		t.Errorf("TestSignedDataVerifier_Notification without data got err:%v, want appAppleId", err)
+	}
+
+	// 未配置断言字段时仅验证签名
+	if got, err := NewSignedDataVerifier("", 0, "", verifier).Notification(JWSNotification(testSign(t, signer, n))); err != nil || got.NotificationUUID != "uuid" {
+		t.Errorf("TestSignedDataVerifier_Notification without data got:%#v, err:%v", got, err)
+	}
+
+	// summary 通知使用 summary 中的字段
+	n = NotificationV2{
+		NotificationType: NotificationV2TypeRenewalExtension,
+		Subtype:          NotificationV2SubtypeSummary,
+		Summary:          NotificationV2Summary{RequestIdentifier: "r", AppAppleID: 1234, BundleID: "com.example", Environment: EnvironmentProduction},
+	}
+	if _, err := v.Notification(JWSNotification(testSign(t, signer, n))); !errors.As(err, &verr) || verr.Status != VerificationStatusInvalidEnvironment {
+		t.Errorf("TestSignedDataVerifier_Notification summary got err:%v", err)
+	}
+}
+
+func TestSignedDataVerifier_AppTransaction(t *testing.T) {
+	signer, verifier := testSigner(t)
+	v := NewSignedDataVerifier("com.example", 0, EnvironmentSandbox, verifier)
+
+	nonce, deviceID := "6F4A1E46-0A1B-4C2D-9E3F-1234567890AB", "B3E4F2A1-9C8D-4E7F-A6B5-0987654321CD"
+	sum := sha512.Sum384([]byte("6f4a1e46-0a1b-4c2d-9e3f-1234567890ab" + "b3e4f2a1-9c8d-4e7f-a6b5-0987654321cd"))
+	a := AppTransaction{
+		BundleID:                   "com.example",
+		ReceiptType:                EnvironmentSandbox,
+		OriginalApplicationVersion: "1.0",
+		DeviceVerification:         base64.StdEncoding.EncodeToString(sum[:]),
+		DeviceVerificationNonce:    nonce,
+	}
+
+	got, err := v.AppTransaction(JWSAppTransaction(testSign(t, signer, a)))
+	if err != nil {
+		t.Errorf("TestSignedDataVerifier_AppTransaction failed. err:%v", err)
+		return
+	}
+
+	if got.OriginalApplicationVersion != "1.0" || !got.VerifyDevice(deviceID) {
+		t.Errorf("TestSignedDataVerifier_AppTransaction got:%#v", got)
+	}
+
+	if got.VerifyDevice("00000000-0000-0000-0000-000000000000") {
+		t.Errorf("TestSignedDataVerifier_AppTransaction: VerifyDevice should reject other device")
+	}
+
+	a.ReceiptType = EnvironmentProduction
+	var verr *VerificationError
+	if _, err := v.AppTransaction(JWSAppTransaction(testSign(t, signer, a))); !errors.As(err, &verr) || verr.Status != VerificationStatusInvalidEnvironment {
+		t.Errorf("TestSignedDataVerifier_AppTransaction got err:%v, want status:%s", err, VerificationStatusInvalidEnvironment)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/beanscc/appstore
 
 go 1.21.3
 
-require github.com/golang-jwt/jwt/v5 v5.2.0
+require (
+	github.com/golang-jwt/jwt/v5 v5.2.0
+	golang.org/x/crypto v0.33.0
+)
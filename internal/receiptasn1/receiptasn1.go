@@ -0,0 +1,53 @@
+// Package receiptasn1 解析 App Store 收据 PKCS#7 内容中的 ASN.1 属性，供 receipt 及 appstoreserverapi 共用
+package receiptasn1
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// 收据 ASN.1 字段类型
+// https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ReceiptFields.html
+const (
+	FieldBundleID                   = 2
+	FieldApplicationVersion         = 3
+	FieldReceiptCreationDate        = 12
+	FieldInApp                      = 17
+	FieldOriginalApplicationVersion = 19
+	FieldExpirationDate             = 21
+
+	FieldQuantity              = 1701
+	FieldProductID             = 1702
+	FieldTransactionID         = 1703
+	FieldPurchaseDate          = 1704
+	FieldOriginalTransactionID = 1705
+	FieldOriginalPurchaseDate  = 1706
+	FieldExpiresDate           = 1708
+	FieldWebOrderLineItemID    = 1711
+	FieldCancellationDate      = 1712
+	FieldIsTrialPeriod         = 1713
+	FieldIsInIntroOfferPeriod  = 1719
+)
+
+// Attribute ReceiptAttribute ::= SEQUENCE { type INTEGER, version INTEGER, value OCTET STRING }
+type Attribute struct {
+	Type    int
+	Version int
+	Value   []byte
+}
+
+// ParseAttributes 解析 Payload ::= SET OF ReceiptAttribute，收据内容及 in_app 字段均为该格式
+func ParseAttributes(payload []byte) ([]Attribute, error) {
+	var attrs []Attribute
+	rest, err := asn1.UnmarshalWithParams(payload, &attrs, "set")
+	if err != nil {
+		return nil, fmt.Errorf("parse receipt attributes: %w", err)
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("parse receipt attributes: trailing data")
+	}
+
+	return attrs, nil
+}
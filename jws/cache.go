@@ -0,0 +1,113 @@
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const defaultCacheSize = 64
+
+// chainFingerprint x5c 证书链的指纹
+func chainFingerprint(x5c []string) string {
+	h := sha256.New()
+	for _, c := range x5c {
+		h.Write([]byte(c))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type chainCacheEntry struct {
+	chain     []*x509.Certificate
+	publicKey *ecdsa.PublicKey
+
+	// 证书链中所有证书的共同有效期
+	notBefore time.Time
+	notAfter  time.Time
+	// 吊销检查结果的有效期，由 chainCache.mutex 保护
+	revocationUntil time.Time
+}
+
+// chainCache 已验证证书链的缓存，验证时间在证书链有效期内时直接返回终端证书公钥
+type chainCache struct {
+	size int
+
+	mutex   sync.RWMutex
+	entries map[string]*chainCacheEntry
+}
+
+func newChainCache(size int) *chainCache {
+	return &chainCache{
+		size:    size,
+		entries: make(map[string]*chainCacheEntry, size),
+	}
+}
+
+func (c *chainCache) get(key string, at time.Time) (*chainCacheEntry, bool) {
+	c.mutex.RLock()
+	entry, ok := c.entries[key]
+	c.mutex.RUnlock()
+
+	if !ok || at.Before(entry.notBefore) || at.After(entry.notAfter) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *chainCache) add(key string, chain []*x509.Certificate, pub *ecdsa.PublicKey, revocationUntil time.Time) {
+	entry := &chainCacheEntry{chain: chain, publicKey: pub, revocationUntil: revocationUntil}
+	for i, cert := range chain {
+		if i == 0 || cert.NotBefore.After(entry.notBefore) {
+			entry.notBefore = cert.NotBefore
+		}
+		if i == 0 || cert.NotAfter.Before(entry.notAfter) {
+			entry.notAfter = cert.NotAfter
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[key] = entry
+}
+
+// revocationValid 证书链的吊销检查结果在 now 时是否有效
+func (c *chainCache) revocationValid(entry *chainCacheEntry, now time.Time) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return now.Before(entry.revocationUntil)
+}
+
+func (c *chainCache) setRevocation(entry *chainCacheEntry, until time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry.revocationUntil = until
+}
+
+// evict 优先删除已过期的证书链，没有过期时删除任意一个
+func (c *chainCache) evict() {
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.notAfter) {
+			delete(c.entries, k)
+		}
+	}
+
+	if len(c.entries) < c.size {
+		return
+	}
+
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package jws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/jws/jwstest"
+)
+
+func TestVerifier_Cache(t *testing.T) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	header := &Header{X5C: signer.X5C()}
+	v := NewVerifier(WithRoots(signer.Roots()), WithCacheSize(1))
+	want, err := v.Verify(header)
+	if err != nil {
+		t.Fatalf("TestVerifier_Cache Verify failed. err:%v", err)
+	}
+
+	if _, ok := v.cache.get(chainFingerprint(header.X5C), time.Now()); !ok {
+		t.Errorf("TestVerifier_Cache: verified chain should be cached")
+	}
+
+	got, err := v.Verify(header)
+	if err != nil || !got.Equal(want) {
+		t.Errorf("TestVerifier_Cache cached got:%v, err:%v", got, err)
+	}
+
+	// 证书链有效期外不使用缓存
+	if _, err := v.verify(context.Background(), header, time.Now().Add(2*time.Hour)); err == nil {
+		t.Errorf("TestVerifier_Cache: expired chain should be rejected")
+	}
+
+	// 超过缓存数量时淘汰
+	other, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+	v.roots.AddCert(other.Root)
+	if _, err := v.Verify(&Header{X5C: other.X5C()}); err != nil {
+		t.Fatalf("TestVerifier_Cache Verify other failed. err:%v", err)
+	}
+	if len(v.cache.entries) != 1 {
+		t.Errorf("TestVerifier_Cache got entries:%d, want:1", len(v.cache.entries))
+	}
+
+	if v := NewVerifier(WithCacheSize(0)); v.cache != nil {
+		t.Errorf("TestVerifier_Cache: WithCacheSize(0) should disable cache")
+	}
+}
+
+func BenchmarkVerifier_VerifyAndBind(b *testing.B) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		b.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	token, err := signer.Sign(map[string]interface{}{"transactionId": "1000000000000001", "signedDate": time.Now().UnixMilli()})
+	if err != nil {
+		b.Fatalf("signer.Sign failed. err:%v", err)
+	}
+
+	run := func(b *testing.B, v *Verifier) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			val, err := Parse(token)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var claims testClaims
+			if err := v.VerifyAndBind(val, &claims); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		run(b, NewVerifier(WithRoots(signer.Roots())))
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		run(b, NewVerifier(WithRoots(signer.Roots()), WithCacheSize(0)))
+	})
+}
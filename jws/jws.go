@@ -6,7 +6,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -52,6 +54,10 @@ type Header struct {
 //   - Certificate(1) Apple intermediate certificate
 //   - Certificate(2) Apple root certificate
 func (h *Header) Certificate(index int) (*x509.Certificate, error) {
+	if index < 0 || index >= len(h.X5C) {
+		return nil, fmt.Errorf("appstore.jws.Header: x5c certificate index out of range, index:%d, len:%d", index, len(h.X5C))
+	}
+
 	bytes, err := base64.StdEncoding.DecodeString(h.X5C[index])
 	if err != nil {
 		return nil, err
@@ -60,81 +66,9 @@ func (h *Header) Certificate(index int) (*x509.Certificate, error) {
 	return x509.ParseCertificate(bytes)
 }
 
-// Verify 验证 x5c 证书链，并返回用于 jws 签名的公钥
+// Verify 使用 DefaultVerifier 验证 x5c 证书链，并返回用于 jws 签名的公钥
 func (h *Header) Verify() (*ecdsa.PublicKey, error) {
-	leaf, err := h.Certificate(0)
-	if err != nil {
-		return nil, err
-	}
-
-	intermediate, err := h.Certificate(1)
-	if err != nil {
-		return nil, err
-	}
-
-	root, err := h.Certificate(2)
-	if err != nil {
-		return nil, err
-	}
-
-	// 验证证书链
-	if err := h.verify(leaf, intermediate, root); err != nil {
-		return nil, err
-	}
-
-	return leaf.PublicKey.(*ecdsa.PublicKey), nil
-}
-
-// verify 验证证书链
-func (h *Header) verify(leaf, intermediate, root *x509.Certificate) error {
-	// 验证终端证书
-	opts := x509.VerifyOptions{
-		Roots:         x509.NewCertPool(),
-		Intermediates: x509.NewCertPool(),
-	}
-	opts.Roots.AddCert(root)
-	opts.Intermediates.AddCert(intermediate)
-
-	_, err := leaf.Verify(opts)
-	if err != nil {
-		return err
-	}
-
-	// // debug 证书
-	// for _, ch := range chains {
-	// 	for _, c := range ch {
-	// 		fmt.Printf("[leaf cert verify] issuer:%s, subject: name:%s, org:%s, org-uni:%s \n",
-	// 			c.Issuer.CommonName, c.Subject.CommonName, c.Subject.Organization, c.Subject.OrganizationalUnit)
-	// 	}
-	// }
-
-	/* output:
-	[leaf cert verify] issuer:Apple Worldwide Developer Relations Certification Authority, subject: name:Prod ECC Mac App Store and iTunes Store Receipt Signing, org:[Apple Inc.], org-uni:[Apple Worldwide Developer Relations]
-	[leaf cert verify] issuer:Apple Root CA - G3, subject: name:Apple Worldwide Developer Relations Certification Authority, org:[Apple Inc.], org-uni:[G6]
-	[leaf cert verify] issuer:Apple Root CA - G3, subject: name:Apple Root CA - G3, org:[Apple Inc.], org-uni:[Apple Certification Authority]
-	*/
-
-	// 使用 Apple PKI 官方提供的根证书验证回调中的 root 证书
-	rootFromApplePKI := x509.NewCertPool()
-	if ok := rootFromApplePKI.AppendCertsFromPEM([]byte(appleRootCertificate)); !ok {
-		return errors.New("failed to append apple root certificate")
-	}
-
-	_, err = root.Verify(x509.VerifyOptions{Roots: rootFromApplePKI})
-	if err != nil {
-		return err
-	}
-
-	// // debug 证书链
-	// for _, ch := range chains {
-	// 	for _, c := range ch {
-	// 		fmt.Printf("[root cert verify] issuer:%s, subject: name:%s, org:%s, org-uni:%s \n",
-	// 			c.Issuer.CommonName, c.Subject.CommonName, c.Subject.Organization, c.Subject.OrganizationalUnit,
-	// 		)
-	// 	}
-	// }
-
-	return nil
+	return DefaultVerifier().Verify(h)
 }
 
 // Parse return JWS by app store jws token
@@ -144,7 +78,7 @@ func Parse(token string) (*JWS, error) {
 		return nil, errors.New("invalid app store JWS token")
 	}
 
-	headerByte, err := base64.RawStdEncoding.DecodeString(parts[0])
+	headerByte, err := base64.RawURLEncoding.DecodeString(parts[0])
 	if err != nil {
 		return nil, err
 	}
@@ -160,16 +94,33 @@ func Parse(token string) (*JWS, error) {
 	}, nil
 }
 
-// VerifyAndBind 验证 jsw 签名及 x5c 证书链，并绑定 jws payload 到 claims
+// SignedDate 返回未经验证的 payload 中的 signedDate，不存在时返回零值
+func (jws *JWS) SignedDate() (time.Time, error) {
+	parts := strings.Split(jws.token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		SignedDate int64 `json:"signedDate"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, err
+	}
+
+	if claims.SignedDate <= 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(claims.SignedDate), nil
+}
+
+// VerifyAndBind 使用 DefaultVerifier 验证 jsw 签名及 x5c 证书链，并绑定 jws payload 到 claims
 //
 //	type claims struct {
 //		jwt.RegisteredClaims
 //		CustomClaims
 //	}
 func (jws *JWS) VerifyAndBind(claims jwt.Claims) error {
-	_, err := jwt.ParseWithClaims(jws.token, claims, func(token *jwt.Token) (interface{}, error) {
-		return jws.Header.Verify()
-	})
-
-	return err
+	return DefaultVerifier().VerifyAndBind(jws, claims)
 }
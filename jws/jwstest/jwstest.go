@@ -0,0 +1,179 @@
+// Package jwstest 生成用于测试的证书链及 App Store 格式的 JWS
+package jwstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// 同 jws.AppleLeafOID, jws.AppleIntermediateOID
+	leafOID         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	intermediateOID = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// Signer 持有 root -> intermediate -> leaf 证书链，使用 leaf 私钥签发 JWS
+type Signer struct {
+	Root         *x509.Certificate
+	Intermediate *x509.Certificate
+	Leaf         *x509.Certificate
+
+	RootKey         *ecdsa.PrivateKey
+	IntermediateKey *ecdsa.PrivateKey
+	LeafKey         *ecdsa.PrivateKey
+}
+
+type options struct {
+	notBefore  time.Time
+	notAfter   time.Time
+	ocspServer string
+}
+
+// Option NewSigner 可选配置
+type Option func(o *options)
+
+// WithValidity 设置证书链的有效期，默认为当前时间前后 1 小时
+func WithValidity(notBefore, notAfter time.Time) Option {
+	return func(o *options) {
+		o.notBefore, o.notAfter = notBefore, notAfter
+	}
+}
+
+// WithOCSPServer 设置终端证书和中间证书的 OCSP 服务地址
+func WithOCSPServer(server string) Option {
+	return func(o *options) {
+		o.ocspServer = server
+	}
+}
+
+// NewSigner 生成 root -> intermediate -> leaf 证书链，证书包含 Apple 的标记扩展
+func NewSigner(opts ...Option) (*Signer, error) {
+	now := time.Now()
+	o := options{notBefore: now.Add(-time.Hour), notAfter: now.Add(time.Hour)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var (
+		s          Signer
+		err        error
+		ocspServer []string
+	)
+	if o.ocspServer != "" {
+		ocspServer = []string{o.ocspServer}
+	}
+	notBefore, notAfter := o.notBefore, o.notAfter
+
+	if s.RootKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	if s.IntermediateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	if s.LeafKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if s.Root, err = create(root, root, &s.RootKey.PublicKey, s.RootKey); err != nil {
+		return nil, err
+	}
+
+	intermediate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		OCSPServer:            ocspServer,
+		ExtraExtensions:       []pkix.Extension{{Id: intermediateOID, Value: []byte{0x05, 0x00}}},
+	}
+	if s.Intermediate, err = create(intermediate, s.Root, &s.IntermediateKey.PublicKey, s.RootKey); err != nil {
+		return nil, err
+	}
+
+	leaf := &x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:       notBefore,
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		OCSPServer:      ocspServer,
+		ExtraExtensions: []pkix.Extension{{Id: leafOID, Value: []byte{0x05, 0x00}}},
+	}
+	if s.Leaf, err = create(leaf, s.Intermediate, &s.LeafKey.PublicKey, s.IntermediateKey); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func create(template, parent *x509.Certificate, pub *ecdsa.PublicKey, priv *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// Roots 返回只包含 Root 的证书池，用于 jws.WithRoots
+func (s *Signer) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	return pool
+}
+
+// X5C 返回 JWS header 中的 x5c 证书链
+func (s *Signer) X5C() []string {
+	return []string{
+		base64.StdEncoding.EncodeToString(s.Leaf.Raw),
+		base64.StdEncoding.EncodeToString(s.Intermediate.Raw),
+		base64.StdEncoding.EncodeToString(s.Root.Raw),
+	}
+}
+
+// Sign 将 payload 序列化为 JSON 并签发 ES256 JWS
+func (s *Signer) Sign(payload interface{}) (string, error) {
+	header, err := json.Marshal(map[string]interface{}{
+		"alg": jwt.SigningMethodES256.Alg(),
+		"x5c": s.X5C(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signingString := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	sig, err := jwt.SigningMethodES256.Sign(signingString, s.LeafKey)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{signingString, base64.RawURLEncoding.EncodeToString(sig)}, "."), nil
+}
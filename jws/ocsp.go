@@ -0,0 +1,274 @@
+package jws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPStatus 证书的吊销状态
+type OCSPStatus int
+
+const (
+	OCSPStatusGood OCSPStatus = iota
+	OCSPStatusRevoked
+	OCSPStatusUnknown
+)
+
+// RevokedError 证书已被吊销
+type RevokedError struct {
+	Subject   string
+	Serial    *big.Int
+	RevokedAt time.Time
+}
+
+func (e *RevokedError) Error() string {
+	return fmt.Sprintf("appstore.jws.OCSPChecker: certificate revoked, subject:%s, serial:%s, revokedAt:%s",
+		e.Subject, e.Serial, e.RevokedAt.Format(time.RFC3339))
+}
+
+// RevocationPolicy 无法确认证书状态（网络错误、响应无效、状态 unknown）时的处理策略
+type RevocationPolicy int
+
+const (
+	// RevocationSoftFail 无法确认证书状态时视为未吊销
+	RevocationSoftFail RevocationPolicy = iota
+	// RevocationHardFail 无法确认证书状态时验证失败
+	RevocationHardFail
+)
+
+// OCSPFetcher 向 OCSP 服务发送请求并返回响应内容
+type OCSPFetcher interface {
+	Fetch(ctx context.Context, server string, request []byte) ([]byte, error)
+}
+
+// OCSPFetcherFunc 函数形式的 OCSPFetcher
+type OCSPFetcherFunc func(ctx context.Context, server string, request []byte) ([]byte, error)
+
+func (f OCSPFetcherFunc) Fetch(ctx context.Context, server string, request []byte) ([]byte, error) {
+	return f(ctx, server, request)
+}
+
+// HTTPFetcher 通过 HTTP POST 发送 OCSP 请求，Client 为 nil 时使用 http.DefaultClient
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, server string, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("appstore.jws.HTTPFetcher: unexpected status code:%d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// OCSPOption OCSPChecker 可选配置
+type OCSPOption func(c *OCSPChecker)
+
+// WithOCSPFetcher 设置 OCSPFetcher，默认使用 &HTTPFetcher{}
+func WithOCSPFetcher(fetcher OCSPFetcher) OCSPOption {
+	return func(c *OCSPChecker) {
+		c.fetcher = fetcher
+	}
+}
+
+// WithRevocationPolicy 设置无法确认证书状态时的处理策略，默认 RevocationSoftFail
+func WithRevocationPolicy(policy RevocationPolicy) OCSPOption {
+	return func(c *OCSPChecker) {
+		c.policy = policy
+	}
+}
+
+// WithOCSPTimeout 设置单次 OCSP 请求的超时时间，默认 5s
+func WithOCSPTimeout(timeout time.Duration) OCSPOption {
+	return func(c *OCSPChecker) {
+		c.timeout = timeout
+	}
+}
+
+type ocspCacheEntry struct {
+	status     OCSPStatus
+	revokedAt  time.Time
+	nextUpdate time.Time
+}
+
+// OCSPChecker 通过 OCSP 检查证书是否被吊销
+//
+// 响应按证书缓存至 nextUpdate，未包含 nextUpdate 的响应不缓存
+type OCSPChecker struct {
+	fetcher OCSPFetcher
+	policy  RevocationPolicy
+	timeout time.Duration
+	now     func() time.Time
+
+	mutex sync.RWMutex
+	cache map[string]ocspCacheEntry
+}
+
+func NewOCSPChecker(opts ...OCSPOption) *OCSPChecker {
+	c := &OCSPChecker{
+		fetcher: &HTTPFetcher{},
+		policy:  RevocationSoftFail,
+		timeout: 5 * time.Second,
+		now:     time.Now,
+		cache:   make(map[string]ocspCacheEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Check 检查 cert 的吊销状态，issuer 为 cert 的签发证书
+func (c *OCSPChecker) Check(ctx context.Context, cert, issuer *x509.Certificate) error {
+	_, err := c.check(ctx, cert, issuer)
+	return err
+}
+
+// check 同 Check，并返回检查结果的有效期：OCSP 响应的 nextUpdate，零值表示不可缓存
+// RevocationSoftFail 时无法确认证书状态的结果不缓存，下次验证时重新检查
+func (c *OCSPChecker) check(ctx context.Context, cert, issuer *x509.Certificate) (time.Time, error) {
+	entry, err := c.status(ctx, cert, issuer)
+	if err != nil {
+		if c.policy == RevocationSoftFail {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("appstore.jws.OCSPChecker: check %s failed: %w", cert.Subject.CommonName, err)
+	}
+
+	switch entry.status {
+	case OCSPStatusRevoked:
+		return time.Time{}, &RevokedError{Subject: cert.Subject.CommonName, Serial: cert.SerialNumber, RevokedAt: entry.revokedAt}
+	case OCSPStatusUnknown:
+		if c.policy == RevocationHardFail {
+			return time.Time{}, fmt.Errorf("appstore.jws.OCSPChecker: unknown status for %s", cert.Subject.CommonName)
+		}
+		return time.Time{}, nil
+	}
+
+	return entry.nextUpdate, nil
+}
+
+func (c *OCSPChecker) status(ctx context.Context, cert, issuer *x509.Certificate) (ocspCacheEntry, error) {
+	issuerHash := sha256.Sum256(issuer.Raw)
+	key := hex.EncodeToString(issuerHash[:]) + "-" + cert.SerialNumber.String()
+	now := c.now()
+
+	c.mutex.RLock()
+	entry, ok := c.cache[key]
+	c.mutex.RUnlock()
+	if ok && now.Before(entry.nextUpdate) {
+		return entry, nil
+	}
+
+	if len(cert.OCSPServer) == 0 {
+		return ocspCacheEntry{}, errors.New("no OCSP server")
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return ocspCacheEntry{}, err
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	raw, err := c.fetcher.Fetch(ctx, cert.OCSPServer[0], req)
+	if err != nil {
+		return ocspCacheEntry{}, err
+	}
+
+	entry, err = parseOCSPResponse(raw, cert, issuer, now)
+	if err != nil {
+		return ocspCacheEntry{}, err
+	}
+
+	if !entry.nextUpdate.IsZero() {
+		c.mutex.Lock()
+		c.cache[key] = entry
+		c.mutex.Unlock()
+	}
+
+	return entry, nil
+}
+
+// parseOCSPResponse 解析并验证 OCSP 响应，响应须由 issuer 或 issuer 授权的 OCSP 签名证书签发
+func parseOCSPResponse(raw []byte, cert, issuer *x509.Certificate, now time.Time) (ocspCacheEntry, error) {
+	resp, err := ocsp.ParseResponseForCert(raw, cert, issuer)
+	if err != nil {
+		return ocspCacheEntry{}, err
+	}
+
+	// ParseResponseForCert 已验证授权证书由 issuer 签发，这里检查其用途及有效期
+	if resp.Certificate != nil && !bytes.Equal(resp.Certificate.Raw, issuer.Raw) {
+		if err := checkOCSPResponder(resp.Certificate, now); err != nil {
+			return ocspCacheEntry{}, err
+		}
+	}
+
+	if resp.ThisUpdate.After(now.Add(5 * time.Minute)) {
+		return ocspCacheEntry{}, errors.New("OCSP response thisUpdate is in the future")
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now) {
+		return ocspCacheEntry{}, errors.New("OCSP response is expired")
+	}
+
+	entry := ocspCacheEntry{nextUpdate: resp.NextUpdate}
+	switch resp.Status {
+	case ocsp.Good:
+		entry.status = OCSPStatusGood
+	case ocsp.Revoked:
+		entry.status, entry.revokedAt = OCSPStatusRevoked, resp.RevokedAt
+	default:
+		entry.status = OCSPStatusUnknown
+	}
+	return entry, nil
+}
+
+// checkOCSPResponder 授权的 OCSP 签名证书须包含 OCSPSigning 用途，且在 now 有效
+func checkOCSPResponder(responder *x509.Certificate, now time.Time) error {
+	if now.Before(responder.NotBefore) || now.After(responder.NotAfter) {
+		return errors.New("OCSP responder certificate is not valid at current time")
+	}
+
+	for _, eku := range responder.ExtKeyUsage {
+		if eku == x509.ExtKeyUsageOCSPSigning {
+			return nil
+		}
+	}
+
+	return errors.New("OCSP responder certificate is not authorized")
+}
@@ -0,0 +1,306 @@
+package jws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/jws/jwstest"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testOCSPResponder 本地 OCSP 服务，使用 jwstest.Signer 的 CA 私钥签发响应
+// delegate 不为 nil 时使用 delegate 签发中间证书（终端证书的 issuer）授权的响应
+type testOCSPResponder struct {
+	signer     *jwstest.Signer
+	revoked    map[int64]bool
+	nextUpdate time.Duration
+	fail       bool
+	delegate   *testOCSPDelegate
+	hits       int32
+}
+
+type testOCSPDelegate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func (r *testOCSPResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt32(&r.hits, 1)
+	if r.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 按序列号选择签发响应的 CA
+	issuer, key := r.signer.Root, r.signer.RootKey
+	if ocspReq.SerialNumber.Cmp(r.signer.Leaf.SerialNumber) == 0 {
+		issuer, key = r.signer.Intermediate, r.signer.IntermediateKey
+	}
+
+	now := time.Now()
+	template := ocsp.Response{Status: ocsp.Good, SerialNumber: ocspReq.SerialNumber, ThisUpdate: now.Add(-time.Minute)}
+	if r.revoked[ocspReq.SerialNumber.Int64()] {
+		template.Status, template.RevokedAt = ocsp.Revoked, now.Add(-time.Hour)
+	}
+	if r.nextUpdate > 0 {
+		template.NextUpdate = now.Add(r.nextUpdate)
+	}
+
+	responder := issuer
+	if r.delegate != nil && issuer == r.signer.Intermediate {
+		responder, key = r.delegate.cert, r.delegate.key
+		template.Certificate = r.delegate.cert
+	}
+
+	out, err := ocsp.CreateResponse(issuer, responder, template, key)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(out)
+}
+
+// testOCSPDelegateCert 生成由中间证书签发的 OCSP 签名证书，ocspSigning 为 false 时不包含 OCSPSigning 用途
+func testOCSPDelegateCert(t *testing.T, signer *jwstest.Signer, ocspSigning bool) *testOCSPDelegate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey failed. err:%v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(10),
+		Subject:      pkix.Name{CommonName: "Test OCSP Responder"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ocspSigning {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.Intermediate, &key.PublicKey, signer.IntermediateKey)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate failed. err:%v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate failed. err:%v", err)
+	}
+
+	return &testOCSPDelegate{cert: cert, key: key}
+}
+
+func testOCSPSetup(t *testing.T) (*jwstest.Signer, *testOCSPResponder) {
+	responder := &testOCSPResponder{revoked: map[int64]bool{}, nextUpdate: time.Hour}
+	srv := httptest.NewServer(responder)
+	t.Cleanup(srv.Close)
+
+	signer, err := jwstest.NewSigner(jwstest.WithOCSPServer(srv.URL))
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+	responder.signer = signer
+
+	return signer, responder
+}
+
+func TestOCSPChecker_Verify(t *testing.T) {
+	signer, responder := testOCSPSetup(t)
+	header := &Header{X5C: signer.X5C()}
+
+	v := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	if _, err := v.Verify(header); err != nil {
+		t.Errorf("TestOCSPChecker_Verify failed. err:%v", err)
+		return
+	}
+
+	// 终端证书和中间证书各请求一次，再次验证使用缓存
+	if _, err := v.Verify(header); err != nil || responder.hits != 2 {
+		t.Errorf("TestOCSPChecker_Verify cached got hits:%d, err:%v", responder.hits, err)
+	}
+
+	// 未包含 nextUpdate 的响应不缓存
+	responder.nextUpdate, responder.hits = 0, 0
+	v = NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	v.Verify(header)
+	v.Verify(header)
+	if responder.hits != 4 {
+		t.Errorf("TestOCSPChecker_Verify without nextUpdate got hits:%d, want:4", responder.hits)
+	}
+}
+
+func TestOCSPChecker_Revoked(t *testing.T) {
+	signer, responder := testOCSPSetup(t)
+	responder.revoked[signer.Leaf.SerialNumber.Int64()] = true
+
+	v := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	_, err := v.Verify(&Header{X5C: signer.X5C()})
+	var revokedErr *RevokedError
+	if !errors.As(err, &revokedErr) {
+		t.Errorf("TestOCSPChecker_Revoked got err:%v, want RevokedError", err)
+	}
+}
+
+func TestOCSPChecker_Policy(t *testing.T) {
+	signer, responder := testOCSPSetup(t)
+	responder.fail = true
+	header := &Header{X5C: signer.X5C()}
+
+	soft := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	if _, err := soft.Verify(header); err != nil {
+		t.Errorf("TestOCSPChecker_Policy soft fail got err:%v", err)
+	}
+
+	hard := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker(WithRevocationPolicy(RevocationHardFail))))
+	if _, err := hard.Verify(header); err == nil {
+		t.Errorf("TestOCSPChecker_Policy hard fail want error")
+	}
+
+	// 自定义 fetcher
+	var fetched int32
+	fetcher := OCSPFetcherFunc(func(ctx context.Context, server string, request []byte) ([]byte, error) {
+		atomic.AddInt32(&fetched, 1)
+		return nil, errors.New("offline")
+	})
+	hard = NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker(WithOCSPFetcher(fetcher), WithRevocationPolicy(RevocationHardFail))))
+	if _, err := hard.Verify(header); err == nil || fetched != 1 {
+		t.Errorf("TestOCSPChecker_Policy custom fetcher got fetched:%d, err:%v", fetched, err)
+	}
+}
+
+func TestOCSPChecker_Context(t *testing.T) {
+	signer, _ := testOCSPSetup(t)
+	header := &Header{X5C: signer.X5C()}
+
+	type key struct{}
+	var got interface{}
+	fetcher := OCSPFetcherFunc(func(ctx context.Context, server string, request []byte) ([]byte, error) {
+		got = ctx.Value(key{})
+		return nil, ctx.Err()
+	})
+	v := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker(WithOCSPFetcher(fetcher), WithRevocationPolicy(RevocationHardFail))))
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "caller"))
+	cancel()
+	if _, err := v.VerifyContext(ctx, header); !errors.Is(err, context.Canceled) || got != "caller" {
+		t.Errorf("TestOCSPChecker_Context got value:%v, err:%v", got, err)
+	}
+}
+
+func TestOCSPChecker_ChainCache(t *testing.T) {
+	signer, responder := testOCSPSetup(t)
+	header := &Header{X5C: signer.X5C()}
+
+	v := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	if _, err := v.Verify(header); err != nil || responder.hits != 2 {
+		t.Errorf("TestOCSPChecker_ChainCache got hits:%d, err:%v", responder.hits, err)
+		return
+	}
+
+	// 证书链缓存命中且吊销检查结果未过 nextUpdate 时不再检查
+	v.revocation.cache = make(map[string]ocspCacheEntry)
+	if _, err := v.Verify(header); err != nil || responder.hits != 2 {
+		t.Errorf("TestOCSPChecker_ChainCache cached got hits:%d, err:%v", responder.hits, err)
+	}
+
+	// RevocationSoftFail 时无法确认证书状态的结果不缓存
+	responder.fail, responder.hits = true, 0
+	v = NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker()))
+	v.Verify(header)
+	v.Verify(header)
+	if responder.hits != 4 {
+		t.Errorf("TestOCSPChecker_ChainCache soft fail got hits:%d, want:4", responder.hits)
+	}
+}
+
+func TestOCSPChecker_MalformedResponse(t *testing.T) {
+	signer, _ := testOCSPSetup(t)
+	header := &Header{X5C: signer.X5C()}
+
+	var valid []byte
+	fetcher := &HTTPFetcher{}
+	capture := OCSPFetcherFunc(func(ctx context.Context, server string, request []byte) ([]byte, error) {
+		raw, err := fetcher.Fetch(ctx, server, request)
+		if valid == nil {
+			valid = raw
+		}
+		return raw, err
+	})
+	if _, err := NewVerifier(WithRoots(signer.Roots()), WithCacheSize(0), WithRevocationChecker(NewOCSPChecker(WithOCSPFetcher(capture)))).Verify(header); err != nil || valid == nil {
+		t.Fatalf("TestOCSPChecker_MalformedResponse failed. err:%v", err)
+	}
+
+	// 解析失败、截断、包含多余数据及签名错误的响应
+	responses := map[string][]byte{
+		"empty":     nil,
+		"garbage":   []byte("not an ocsp response"),
+		"truncated": valid[:len(valid)/2],
+		"trailing":  append(append([]byte{}, valid...), 0x00),
+		"corrupted": append(append([]byte{}, valid[:len(valid)-1]...), valid[len(valid)-1]^0xff),
+	}
+	for name, raw := range responses {
+		raw := raw
+		fetcher := OCSPFetcherFunc(func(ctx context.Context, server string, request []byte) ([]byte, error) {
+			return raw, nil
+		})
+
+		hard := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker(WithOCSPFetcher(fetcher), WithRevocationPolicy(RevocationHardFail))))
+		if _, err := hard.Verify(header); err == nil {
+			t.Errorf("TestOCSPChecker_MalformedResponse %s: hard fail want error", name)
+		}
+
+		soft := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(NewOCSPChecker(WithOCSPFetcher(fetcher))))
+		if _, err := soft.Verify(header); err != nil {
+			t.Errorf("TestOCSPChecker_MalformedResponse %s: soft fail got err:%v", name, err)
+		}
+	}
+}
+
+func TestOCSPChecker_DelegatedResponder(t *testing.T) {
+	signer, responder := testOCSPSetup(t)
+	header := &Header{X5C: signer.X5C()}
+	checker := func() *OCSPChecker { return NewOCSPChecker(WithRevocationPolicy(RevocationHardFail)) }
+
+	// 中间证书授权的 OCSP 签名证书
+	responder.delegate = testOCSPDelegateCert(t, signer, true)
+	if _, err := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(checker())).Verify(header); err != nil {
+		t.Errorf("TestOCSPChecker_DelegatedResponder failed. err:%v", err)
+	}
+
+	// 不包含 OCSPSigning 用途的证书不能签发响应
+	responder.delegate = testOCSPDelegateCert(t, signer, false)
+	if _, err := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(checker())).Verify(header); err == nil {
+		t.Errorf("TestOCSPChecker_DelegatedResponder: responder without OCSPSigning should be rejected")
+	}
+
+	// 由其他 CA 签发的 OCSP 签名证书
+	other, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+	responder.delegate = testOCSPDelegateCert(t, other, true)
+	if _, err := NewVerifier(WithRoots(signer.Roots()), WithRevocationChecker(checker())).Verify(header); err == nil {
+		t.Errorf("TestOCSPChecker_DelegatedResponder: responder from other issuer should be rejected")
+	}
+}
@@ -0,0 +1,339 @@
+package jws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// AppleLeafOID Apple 签名终端证书的标记扩展
+	AppleLeafOID = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	// AppleIntermediateOID Apple WWDR 中间证书的标记扩展
+	AppleIntermediateOID = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+var (
+	ErrInvalidChainLength = errors.New("appstore.jws.Verifier: x5c must contain 3 certificates")
+	ErrMissingLeafOID     = errors.New("appstore.jws.Verifier: leaf certificate missing required extension")
+	ErrMissingInterOID    = errors.New("appstore.jws.Verifier: intermediate certificate missing required extension")
+	ErrInvalidPublicKey   = errors.New("appstore.jws.Verifier: leaf certificate public key is not ECDSA")
+)
+
+// appleRoots 内置的 Apple Root CA - G3 证书
+var appleRoots = func() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if ok := pool.AppendCertsFromPEM([]byte(appleRootCertificate)); !ok {
+		panic("appstore.jws: failed to append apple root certificate")
+	}
+	return pool
+}()
+
+var defaultVerifier atomic.Pointer[Verifier]
+
+func init() {
+	defaultVerifier.Store(NewVerifier())
+}
+
+// DefaultVerifier 返回 Header.Verify 及 JWS.VerifyAndBind 使用的 Verifier
+func DefaultVerifier() *Verifier {
+	return defaultVerifier.Load()
+}
+
+// SetDefaultVerifier 替换 DefaultVerifier，v 为 nil 时恢复为 NewVerifier()
+func SetDefaultVerifier(v *Verifier) {
+	if v == nil {
+		v = NewVerifier()
+	}
+	defaultVerifier.Store(v)
+}
+
+// VerifierOption Verifier 可选配置
+type VerifierOption func(v *Verifier)
+
+// WithRoots 设置信任的根证书，默认使用内置的 Apple Root CA - G3
+func WithRoots(roots *x509.CertPool) VerifierOption {
+	return func(v *Verifier) {
+		v.roots = roots
+	}
+}
+
+// WithLeafOID 设置终端证书必须包含的扩展，默认 AppleLeafOID，nil 表示不检查
+func WithLeafOID(oid asn1.ObjectIdentifier) VerifierOption {
+	return func(v *Verifier) {
+		v.leafOID = oid
+	}
+}
+
+// WithIntermediateOID 设置中间证书必须包含的扩展，默认 AppleIntermediateOID，nil 表示不检查
+func WithIntermediateOID(oid asn1.ObjectIdentifier) VerifierOption {
+	return func(v *Verifier) {
+		v.intermediateOID = oid
+	}
+}
+
+// WithVerificationTime 设置验证证书有效期使用的时间，设置后 TimePolicy 不再生效
+func WithVerificationTime(t time.Time) VerifierOption {
+	return func(v *Verifier) {
+		v.verificationTime = t
+	}
+}
+
+// TimePolicy 验证证书有效期时使用的时间
+type TimePolicy int
+
+const (
+	// TimePolicyAuto 开启在线吊销检查时使用当前时间，否则使用 payload 的 signedDate
+	TimePolicyAuto TimePolicy = iota
+	// TimePolicyCurrent 使用当前时间，证书过期后此前签发的 JWS 将验证失败
+	TimePolicyCurrent
+	// TimePolicySignedDate 使用 payload 的 signedDate，用于验证历史交易和重放的通知
+	TimePolicySignedDate
+)
+
+// WithTimePolicy 设置验证证书有效期使用的时间策略，默认 TimePolicyAuto
+// payload 不包含 signedDate 时使用当前时间
+func WithTimePolicy(policy TimePolicy) VerifierOption {
+	return func(v *Verifier) {
+		v.timePolicy = policy
+	}
+}
+
+// WithRevocationChecker 设置在线吊销检查，检查终端证书和中间证书，默认不检查
+func WithRevocationChecker(checker *OCSPChecker) VerifierOption {
+	return func(v *Verifier) {
+		v.revocation = checker
+	}
+}
+
+// WithCacheSize 设置已验证证书链缓存的最大数量，默认 64，0 表示不缓存
+// 缓存同时保存吊销检查结果，有效期至 OCSP 响应的 nextUpdate
+func WithCacheSize(size int) VerifierOption {
+	return func(v *Verifier) {
+		v.cache = nil
+		if size > 0 {
+			v.cache = newChainCache(size)
+		}
+	}
+}
+
+// Verifier 验证 App Store JWS 的 x5c 证书链及签名
+// 文档：https://developer.apple.com/documentation/appstoreserverapi/jwsdecodedheader
+//
+// x5c 中的根证书不被信任，证书链验证到 WithRoots 配置的根证书
+type Verifier struct {
+	roots           *x509.CertPool
+	leafOID         asn1.ObjectIdentifier
+	intermediateOID asn1.ObjectIdentifier
+
+	// 不为零值时优先使用
+	verificationTime time.Time
+	timePolicy       TimePolicy
+	// 为 nil 时不检查证书吊销状态
+	revocation *OCSPChecker
+	// 已验证的证书链，为 nil 时不缓存
+	cache *chainCache
+}
+
+func NewVerifier(opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		roots:           appleRoots,
+		leafOID:         AppleLeafOID,
+		intermediateOID: AppleIntermediateOID,
+		cache:           newChainCache(defaultCacheSize),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// Verify 验证 x5c 证书链，并返回用于 jws 签名的公钥
+// 仅有 header 时无法获取 signedDate，除 WithVerificationTime 外均使用当前时间验证
+func (v *Verifier) Verify(h *Header) (*ecdsa.PublicKey, error) {
+	return v.VerifyContext(context.Background(), h)
+}
+
+// VerifyContext 同 Verify，ctx 用于在线吊销检查的请求
+func (v *Verifier) VerifyContext(ctx context.Context, h *Header) (*ecdsa.PublicKey, error) {
+	return v.verify(ctx, h, v.currentTime())
+}
+
+func (v *Verifier) verify(ctx context.Context, h *Header, at time.Time) (*ecdsa.PublicKey, error) {
+	if len(h.X5C) != 3 {
+		return nil, ErrInvalidChainLength
+	}
+
+	var key string
+	if v.cache != nil {
+		key = chainFingerprint(h.X5C)
+		if entry, ok := v.cache.get(key, at); ok {
+			// 吊销检查结果在有效期内时不再检查
+			if v.revocation != nil && !v.cache.revocationValid(entry, time.Now()) {
+				until, err := v.checkRevocation(ctx, entry.chain)
+				if err != nil {
+					return nil, err
+				}
+				v.cache.setRevocation(entry, until)
+			}
+			return entry.publicKey, nil
+		}
+	}
+
+	leaf, err := h.Certificate(0)
+	if err != nil {
+		return nil, err
+	}
+
+	intermediate, err := h.Certificate(1)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := v.verifyChain(leaf, intermediate, at)
+	if err != nil {
+		return nil, err
+	}
+
+	until, err := v.checkRevocation(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, ErrInvalidPublicKey
+	}
+
+	if v.cache != nil {
+		v.cache.add(key, chain, pub, until)
+	}
+
+	return pub, nil
+}
+
+// VerifyAndBind 验证 jsw 签名及 x5c 证书链，并绑定 jws payload 到 claims
+// 证书有效期按 TimePolicy 验证
+func (v *Verifier) VerifyAndBind(jws *JWS, claims jwt.Claims) error {
+	return v.VerifyAndBindContext(context.Background(), jws, claims)
+}
+
+// VerifyAndBindContext 同 VerifyAndBind，ctx 用于在线吊销检查的请求
+func (v *Verifier) VerifyAndBindContext(ctx context.Context, jws *JWS, claims jwt.Claims) error {
+	at, err := v.verificationTimeOf(jws)
+	if err != nil {
+		return err
+	}
+
+	_, err = jwt.ParseWithClaims(jws.token, claims, func(token *jwt.Token) (interface{}, error) {
+		return v.verify(ctx, jws.Header, at)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+
+	return err
+}
+
+func (v *Verifier) currentTime() time.Time {
+	if v.verificationTime.IsZero() {
+		return time.Now()
+	}
+	return v.verificationTime
+}
+
+// verificationTimeOf 按 TimePolicy 返回验证 jws 证书链使用的时间
+func (v *Verifier) verificationTimeOf(jws *JWS) (time.Time, error) {
+	if !v.verificationTime.IsZero() {
+		return v.verificationTime, nil
+	}
+
+	policy := v.timePolicy
+	if policy == TimePolicyAuto {
+		policy = TimePolicySignedDate
+		if v.revocation != nil {
+			policy = TimePolicyCurrent
+		}
+	}
+
+	if policy == TimePolicyCurrent {
+		return time.Now(), nil
+	}
+
+	// signedDate 在签名验证前读取，签名仍须由当时有效的证书签发
+	signedDate, err := jws.SignedDate()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if signedDate.IsZero() {
+		return time.Now(), nil
+	}
+	return signedDate, nil
+}
+
+// verifyChain 验证证书链：leaf -> intermediate -> roots，返回验证通过的证书链
+func (v *Verifier) verifyChain(leaf, intermediate *x509.Certificate, at time.Time) ([]*x509.Certificate, error) {
+	if !hasExtension(leaf, v.leafOID) {
+		return nil, ErrMissingLeafOID
+	}
+
+	if !hasExtension(intermediate, v.intermediateOID) {
+		return nil, ErrMissingInterOID
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	opts.Intermediates.AddCert(intermediate)
+
+	chains, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, fmt.Errorf("appstore.jws.Verifier: verify certificate chain failed: %w", err)
+	}
+
+	// chains[0]: leaf, intermediate, root
+	return chains[0], nil
+}
+
+// checkRevocation 检查证书链中除根证书外的证书吊销状态，返回检查结果的有效期，零值表示不可缓存
+func (v *Verifier) checkRevocation(ctx context.Context, chain []*x509.Certificate) (time.Time, error) {
+	if v.revocation == nil {
+		return time.Time{}, nil
+	}
+
+	var until time.Time
+	for i := 0; i < len(chain)-1; i++ {
+		next, err := v.revocation.check(ctx, chain[i], chain[i+1])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if i == 0 || next.Before(until) {
+			until = next
+		}
+	}
+
+	return until, nil
+}
+
+// hasExtension oid 为空时返回 true
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	if len(oid) == 0 {
+		return true
+	}
+
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
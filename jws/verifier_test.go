@@ -0,0 +1,135 @@
+package jws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/jws/jwstest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testClaims struct {
+	jwt.RegisteredClaims
+
+	TransactionID string `json:"transactionId"`
+	SignedDate    int64  `json:"signedDate"`
+}
+
+func TestVerifier_VerifyAndBind(t *testing.T) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	token, err := signer.Sign(map[string]interface{}{"transactionId": "1000000000000001"})
+	if err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+
+	val, err := Parse(token)
+	if err != nil {
+		t.Fatalf("Parse failed. err:%v", err)
+	}
+
+	// 内置 Apple 根证书不能验证测试证书链
+	var claims testClaims
+	if err := val.VerifyAndBind(&claims); err == nil {
+		t.Errorf("TestVerifier_VerifyAndBind: default verifier should reject test chain")
+	}
+
+	v := NewVerifier(WithRoots(signer.Roots()))
+	if err := v.VerifyAndBind(val, &claims); err != nil {
+		t.Errorf("TestVerifier_VerifyAndBind failed. err:%v", err)
+		return
+	}
+
+	if claims.TransactionID != "1000000000000001" {
+		t.Errorf("TestVerifier_VerifyAndBind got transactionId:%s", claims.TransactionID)
+	}
+
+	// 有效期外
+	v = NewVerifier(WithRoots(signer.Roots()), WithVerificationTime(time.Now().Add(2*time.Hour)))
+	if err := v.VerifyAndBind(val, &claims); err == nil {
+		t.Errorf("TestVerifier_VerifyAndBind: expired chain should be rejected")
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	other, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	x5c := signer.X5C()
+	tests := []struct {
+		name    string
+		header  *Header
+		opts    []VerifierOption
+		wantErr bool
+		is      error
+	}{
+		{name: "ok", header: &Header{X5C: x5c}},
+		{name: "chain length", header: &Header{X5C: x5c[:2]}, wantErr: true, is: ErrInvalidChainLength},
+		{name: "leaf oid", header: &Header{X5C: x5c}, opts: []VerifierOption{WithLeafOID([]int{1, 2, 3})}, wantErr: true, is: ErrMissingLeafOID},
+		{name: "intermediate oid", header: &Header{X5C: x5c}, opts: []VerifierOption{WithIntermediateOID([]int{1, 2, 3})}, wantErr: true, is: ErrMissingInterOID},
+		{name: "untrusted root", header: &Header{X5C: x5c}, opts: []VerifierOption{WithRoots(other.Roots())}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		opts := append([]VerifierOption{WithRoots(signer.Roots())}, tt.opts...)
+		_, err := NewVerifier(opts...).Verify(tt.header)
+		if (err != nil) != tt.wantErr || (tt.is != nil && !errors.Is(err, tt.is)) {
+			t.Errorf("TestVerifier_Verify %s: got err:%v, wantErr:%v, is:%v", tt.name, err, tt.wantErr, tt.is)
+		}
+	}
+}
+
+func TestVerifier_TimePolicy(t *testing.T) {
+	now := time.Now()
+	// 证书已过期，JWS 在证书有效期内签发
+	signer, err := jwstest.NewSigner(jwstest.WithValidity(now.Add(-48*time.Hour), now.Add(-24*time.Hour)))
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	sign := func(signedDate time.Time) *JWS {
+		token, err := signer.Sign(map[string]interface{}{"transactionId": "1", "signedDate": signedDate.UnixMilli()})
+		if err != nil {
+			t.Fatalf("signer.Sign failed. err:%v", err)
+		}
+		val, err := Parse(token)
+		if err != nil {
+			t.Fatalf("Parse failed. err:%v", err)
+		}
+		return val
+	}
+
+	tests := []struct {
+		name    string
+		jws     *JWS
+		opts    []VerifierOption
+		wantErr bool
+	}{
+		{name: "auto", jws: sign(now.Add(-36 * time.Hour))},
+		{name: "signed date", jws: sign(now.Add(-36 * time.Hour)), opts: []VerifierOption{WithTimePolicy(TimePolicySignedDate)}},
+		{name: "current", jws: sign(now.Add(-36 * time.Hour)), opts: []VerifierOption{WithTimePolicy(TimePolicyCurrent)}, wantErr: true},
+		{name: "signed after expiry", jws: sign(now.Add(-time.Hour)), wantErr: true},
+		{name: "verification time", jws: sign(now.Add(-36 * time.Hour)), opts: []VerifierOption{WithVerificationTime(now)}, wantErr: true},
+		{name: "auto with revocation", jws: sign(now.Add(-36 * time.Hour)), opts: []VerifierOption{WithRevocationChecker(NewOCSPChecker())}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		opts := append([]VerifierOption{WithRoots(signer.Roots())}, tt.opts...)
+		var claims testClaims
+		err := NewVerifier(opts...).VerifyAndBind(tt.jws, &claims)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("TestVerifier_TimePolicy %s: got err:%v, wantErr:%v", tt.name, err, tt.wantErr)
+		}
+	}
+}
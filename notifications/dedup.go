@@ -0,0 +1,418 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State 通知的处理状态
+type State int
+
+const (
+	// StateNone 未处理过
+	StateNone State = iota
+	// StateInProgress 正在处理中
+	StateInProgress
+	// StateDone 已处理成功
+	StateDone
+	// StateFailed 处理失败，可重新处理
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNone:
+		return "none"
+	case StateInProgress:
+		return "in-progress"
+	case StateDone:
+		return "done"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// ErrInProgress 相同 notificationUUID 的通知正在被处理
+var ErrInProgress = errors.New("appstore.notifications: notification is in progress")
+
+// DedupStore 记录通知的处理状态，用于对 App Store 重试及 GetNotificationHistory 重放的通知去重
+type DedupStore interface {
+	// Acquire 尝试获取 uuid 的处理权，成功时状态被置为 StateInProgress 并返回 true；
+	// 通知正在处理中或已处理成功时返回 false 及当前状态
+	Acquire(ctx context.Context, uuid string) (bool, State, error)
+	// Release 记录处理结果，state 为 StateDone 或 StateFailed
+	Release(ctx context.Context, uuid string, state State) error
+}
+
+// Dedup 在调用 fn 前通过 store 对通知去重
+//   - 已处理成功的通知直接返回 nil，不再调用 fn
+//   - 正在处理中的通知返回 ErrInProgress，App Store 会稍后重试
+//   - fn 处理失败的通知可被再次处理
+func Dedup(store DedupStore, fn HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, n *Notification) error {
+		uuid := n.NotificationUUID
+		if uuid == "" {
+			return fn(ctx, n)
+		}
+
+		ok, state, err := store.Acquire(ctx, uuid)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			if state == StateDone {
+				return nil
+			}
+			return ErrInProgress
+		}
+
+		// 处理结果需要记录，不受 ctx 取消影响
+		releaseCtx := context.WithoutCancel(ctx)
+		if err := fn(ctx, n); err != nil {
+			if releaseErr := store.Release(releaseCtx, uuid, StateFailed); releaseErr != nil {
+				return errors.Join(err, releaseErr)
+			}
+			return err
+		}
+
+		return store.Release(releaseCtx, uuid, StateDone)
+	}
+}
+
+type dedupRecord struct {
+	State     State     `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// acquirable 判断记录是否可被重新获取处理权
+//   - 处理失败
+//   - 处理中超过 lease，认为处理进程已退出
+//   - 处理成功超过 ttl，记录已过期
+func (r dedupRecord) acquirable(now time.Time, ttl, lease time.Duration) bool {
+	switch r.State {
+	case StateInProgress:
+		return now.Sub(r.UpdatedAt) >= lease
+	case StateDone:
+		return now.Sub(r.UpdatedAt) >= ttl
+	}
+	return true
+}
+
+// MemoryStore 基于内存的 DedupStore，仅适用于单进程
+type MemoryStore struct {
+	ttl   time.Duration
+	lease time.Duration
+
+	mutex     sync.Mutex
+	records   map[string]dedupRecord
+	lastSweep time.Time
+}
+
+// NewMemoryStore ttl 或 lease 不大于 0 时 panic
+//   - ttl: 处理成功的记录保留时长，应大于 App Store 的重试周期
+//   - lease: 处理中状态的最长时间，超过后允许其他请求重新处理
+func NewMemoryStore(ttl, lease time.Duration) *MemoryStore {
+	if err := checkDedupDurations(ttl, lease); err != nil {
+		panic(err)
+	}
+
+	return &MemoryStore{
+		ttl:     ttl,
+		lease:   lease,
+		records: make(map[string]dedupRecord),
+	}
+}
+
+func (s *MemoryStore) Acquire(ctx context.Context, uuid string) (bool, State, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if r, ok := s.records[uuid]; ok && !r.acquirable(now, s.ttl, s.lease) {
+		return false, r.State, nil
+	}
+
+	s.records[uuid] = dedupRecord{State: StateInProgress, UpdatedAt: now}
+	return true, StateInProgress, nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, uuid string, state State) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.records[uuid] = dedupRecord{State: state, UpdatedAt: time.Now()}
+	return nil
+}
+
+// sweep 清理过期记录，每分钟最多执行一次
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+
+	s.lastSweep = now
+	for k, r := range s.records {
+		if r.State != StateInProgress && r.acquirable(now, s.ttl, s.lease) {
+			delete(s.records, k)
+		}
+	}
+}
+
+func checkDedupDurations(ttl, lease time.Duration) error {
+	if ttl <= 0 || lease <= 0 {
+		return fmt.Errorf("appstore.notifications: ttl and lease must be positive, got ttl:%s, lease:%s", ttl, lease)
+	}
+	return nil
+}
+
+// 默认的文件锁超时时间，超过后认为持有锁的进程已退出
+const defaultFileLockTimeout = 10 * time.Second
+
+// FileStoreOption FileStore 可选配置
+type FileStoreOption func(s *FileStore)
+
+// WithLockTimeout 设置文件锁超时时间，超过后认为持有锁的进程已退出，默认 10s
+// 应大于单次读写状态文件的最长时间，仅用于不支持 flock 的平台
+func WithLockTimeout(timeout time.Duration) FileStoreOption {
+	return func(s *FileStore) {
+		if timeout > 0 {
+			s.lockTimeout = timeout
+		}
+	}
+}
+
+// FileStore 基于文件的 DedupStore，每个通知一个状态文件
+// unix 平台使用 flock 加锁，可用于共享同一目录（本地文件系统）的多个进程；
+// 其他平台通过锁文件超时接管退出进程的锁，接管不是原子操作，仅适用于单进程
+//
+// FileStore 不会自动清理过期的状态文件，应定期调用 Purge
+type FileStore struct {
+	dir         string
+	ttl         time.Duration
+	lease       time.Duration
+	lockTimeout time.Duration
+
+	// 进程内的锁，减少文件锁的竞争
+	mutex sync.Mutex
+}
+
+// NewFileStore 参数 ttl, lease 同 NewMemoryStore，不大于 0 时返回 error
+func NewFileStore(dir string, ttl, lease time.Duration, opts ...FileStoreOption) (*FileStore, error) {
+	if err := checkDedupDurations(ttl, lease); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileStore{
+		dir:         dir,
+		ttl:         ttl,
+		lease:       lease,
+		lockTimeout: defaultFileLockTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Purge 删除 olderThan 之前更新的状态文件（处理中且未超过 lease 的除外），及残留的锁文件和临时文件，返回删除的状态文件数量
+// olderThan 小于 ttl 时，已处理成功的通知可能被再次处理
+// Purge 需要遍历整个目录，应在后台定期调用，不应在处理通知的请求中调用
+func (s *FileStore) Purge(ctx context.Context, olderThan time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+
+		name := entry.Name()
+		switch filepath.Ext(name) {
+		case ".json":
+		case ".lock":
+			// 进程退出后残留的锁文件，获取锁后删除
+			if unlock, ok, err := tryLock(filepath.Join(s.dir, name), s.lockTimeout); err == nil && ok {
+				unlock()
+			}
+			continue
+		case ".tmp":
+			// 写入状态文件时退出残留的临时文件，持有状态文件的锁时删除
+			if path, err := s.path(strings.TrimSuffix(name, ".json.tmp")); err == nil {
+				err = s.withLock(ctx, path, func() error {
+					if err := os.Remove(path + ".tmp"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+						return err
+					}
+					return nil
+				})
+				if err != nil {
+					return purged, err
+				}
+			}
+			continue
+		default:
+			continue
+		}
+
+		path, err := s.path(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+
+		err = s.withLock(ctx, path, func() error {
+			r, err := s.read(path)
+			if err != nil || r == nil {
+				return err
+			}
+
+			now := time.Now()
+			if now.Sub(r.UpdatedAt) < olderThan || r.State == StateInProgress && now.Sub(r.UpdatedAt) < s.lease {
+				return nil
+			}
+
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			purged++
+			return nil
+		})
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+func (s *FileStore) Acquire(ctx context.Context, uuid string) (bool, State, error) {
+	path, err := s.path(uuid)
+	if err != nil {
+		return false, StateNone, err
+	}
+
+	var (
+		acquired bool
+		state    State
+	)
+	err = s.withLock(ctx, path, func() error {
+		r, err := s.read(path)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if r != nil && !r.acquirable(now, s.ttl, s.lease) {
+			state = r.State
+			return nil
+		}
+
+		acquired, state = true, StateInProgress
+		return s.write(path, dedupRecord{State: StateInProgress, UpdatedAt: now})
+	})
+
+	return acquired, state, err
+}
+
+func (s *FileStore) Release(ctx context.Context, uuid string, state State) error {
+	path, err := s.path(uuid)
+	if err != nil {
+		return err
+	}
+
+	return s.withLock(ctx, path, func() error {
+		return s.write(path, dedupRecord{State: state, UpdatedAt: time.Now()})
+	})
+}
+
+// path 返回 uuid 对应的状态文件，uuid 仅允许字母、数字和 '-'，避免路径穿越
+func (s *FileStore) path(uuid string) (string, error) {
+	if uuid == "" {
+		return "", errors.New("appstore.notifications.FileStore: empty uuid")
+	}
+
+	for _, c := range uuid {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+			return "", fmt.Errorf("appstore.notifications.FileStore: invalid uuid:%q", uuid)
+		}
+	}
+
+	return filepath.Join(s.dir, uuid+".json"), nil
+}
+
+// withLock 持有 path 的文件锁执行 fn
+func (s *FileStore) withLock(ctx context.Context, path string, fn func() error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lock := path + ".lock"
+	for {
+		unlock, ok, err := tryLock(lock, s.lockTimeout)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			defer unlock()
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	return fn()
+}
+
+func (s *FileStore) read(path string) (*dedupRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var r dedupRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// write 先写临时文件再 rename，保证状态文件完整
+func (s *FileStore) write(path string, r dedupRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
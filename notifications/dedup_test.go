@@ -0,0 +1,218 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+func testNotification(uuid string) *Notification {
+	return &Notification{NotificationV2: appstoreserverapi.NotificationV2{
+		NotificationType: appstoreserverapi.NotificationV2TypeDidRenew,
+		NotificationUUID: uuid,
+	}}
+}
+
+func testDedupStores(t *testing.T) map[string]DedupStore {
+	fileStore, err := NewFileStore(t.TempDir(), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("NewFileStore failed. err:%v", err)
+	}
+
+	return map[string]DedupStore{
+		"memory": NewMemoryStore(time.Hour, time.Minute),
+		"file":   fileStore,
+	}
+}
+
+func TestDedup_Concurrent(t *testing.T) {
+	for name, store := range testDedupStores(t) {
+		var calls int32
+		release := make(chan struct{})
+		fn := Dedup(store, func(ctx context.Context, n *Notification) error {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return nil
+		})
+
+		var (
+			wg         sync.WaitGroup
+			inProgress int32
+		)
+		first := make(chan struct{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			close(first)
+			if err := fn(context.Background(), testNotification("uuid-1")); err != nil {
+				t.Errorf("TestDedup_Concurrent %s: first call failed. err:%v", name, err)
+			}
+		}()
+		<-first
+
+		// 等待第一个请求获取处理权
+		for i := 0; i < 100 && atomic.LoadInt32(&calls) == 0; i++ {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := fn(context.Background(), testNotification("uuid-1")); errors.Is(err, ErrInProgress) {
+					atomic.AddInt32(&inProgress, 1)
+				}
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if calls != 1 || inProgress != 5 {
+			t.Errorf("TestDedup_Concurrent %s: got calls:%d, in-progress:%d, want calls:1, in-progress:5", name, calls, inProgress)
+		}
+
+		// 已处理成功的通知不再处理
+		if err := fn(context.Background(), testNotification("uuid-1")); err != nil || calls != 1 {
+			t.Errorf("TestDedup_Concurrent %s: duplicate got calls:%d, err:%v", name, calls, err)
+		}
+	}
+}
+
+func TestDedup_Failed(t *testing.T) {
+	for name, store := range testDedupStores(t) {
+		calls := 0
+		fn := Dedup(store, func(ctx context.Context, n *Notification) error {
+			calls++
+			if calls == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+
+		if err := fn(context.Background(), testNotification("uuid-2")); err == nil {
+			t.Errorf("TestDedup_Failed %s: first call should fail", name)
+		}
+
+		if err := fn(context.Background(), testNotification("uuid-2")); err != nil || calls != 2 {
+			t.Errorf("TestDedup_Failed %s: retry got calls:%d, err:%v", name, calls, err)
+		}
+
+		ok, state, err := store.Acquire(context.Background(), "uuid-2")
+		if err != nil || ok || state != StateDone {
+			t.Errorf("TestDedup_Failed %s: Acquire got ok:%v, state:%s, err:%v", name, ok, state, err)
+		}
+	}
+}
+
+func TestFileStore_InvalidUUID(t *testing.T) {
+	store, err := NewFileStore(t.TempDir(), time.Hour, time.Minute)
+	if err != nil {
+		t.Fatalf("NewFileStore failed. err:%v", err)
+	}
+
+	if _, _, err := store.Acquire(context.Background(), "../uuid"); err == nil {
+		t.Errorf("TestFileStore_InvalidUUID: want error")
+	}
+}
+
+func TestFileStore_Purge(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, time.Hour, time.Minute, WithLockTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewFileStore failed. err:%v", err)
+	}
+
+	ctx := context.Background()
+	store.Acquire(ctx, "uuid-done")
+	store.Release(ctx, "uuid-done", StateDone)
+	store.Acquire(ctx, "uuid-in-progress")
+
+	// 进程退出后残留的锁文件
+	stale := filepath.Join(dir, "uuid-crashed.json.lock")
+	os.WriteFile(stale, nil, 0o644)
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(stale, old, old)
+
+	if n, err := store.Purge(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("TestFileStore_Purge got purged:%d, err:%v, want:0", n, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("TestFileStore_Purge: stale lock file should be removed")
+	}
+
+	// 处理中且未超过 lease 的记录保留
+	if n, err := store.Purge(ctx, 0); err != nil || n != 1 {
+		t.Errorf("TestFileStore_Purge got purged:%d, err:%v, want:1", n, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "uuid-in-progress.json" {
+		t.Errorf("TestFileStore_Purge got entries:%v", entries)
+	}
+}
+
+func TestFileStore_MultiProcess(t *testing.T) {
+	// 共享同一目录的多个 FileStore 模拟多个进程，进程内的锁互不影响
+	dir := t.TempDir()
+	stores := make([]*FileStore, 8)
+	for i := range stores {
+		store, err := NewFileStore(dir, time.Hour, time.Minute)
+		if err != nil {
+			t.Fatalf("NewFileStore failed. err:%v", err)
+		}
+		stores[i] = store
+	}
+
+	for round := 0; round < 20; round++ {
+		uuid := "uuid-" + strconv.Itoa(round)
+
+		var (
+			wg       sync.WaitGroup
+			acquired int32
+		)
+		for _, store := range stores {
+			wg.Add(1)
+			go func(store *FileStore) {
+				defer wg.Done()
+				ok, _, err := store.Acquire(context.Background(), uuid)
+				if err != nil {
+					t.Errorf("TestFileStore_MultiProcess Acquire failed. err:%v", err)
+				}
+				if ok {
+					atomic.AddInt32(&acquired, 1)
+				}
+			}(store)
+		}
+		wg.Wait()
+
+		if acquired != 1 {
+			t.Errorf("TestFileStore_MultiProcess %s got acquired:%d, want:1", uuid, acquired)
+		}
+	}
+}
+
+func TestNewStore_InvalidDuration(t *testing.T) {
+	if _, err := NewFileStore(t.TempDir(), 0, time.Minute); err == nil {
+		t.Errorf("TestNewStore_InvalidDuration: NewFileStore should reject ttl 0")
+	}
+	if _, err := NewFileStore(t.TempDir(), time.Hour, -time.Minute); err == nil {
+		t.Errorf("TestNewStore_InvalidDuration: NewFileStore should reject negative lease")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("TestNewStore_InvalidDuration: NewMemoryStore should panic on lease 0")
+		}
+	}()
+	NewMemoryStore(time.Hour, 0)
+}
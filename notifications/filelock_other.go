@@ -0,0 +1,29 @@
+//go:build !unix
+
+package notifications
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// tryLock 以 O_EXCL 创建锁文件，锁文件超过 timeout 时认为持有锁的进程已退出
+// 接管超时的锁不是原子操作，多个进程可能同时接管，仅适用于单进程
+func tryLock(lock string, timeout time.Duration) (unlock func(), ok bool, err error) {
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err == nil {
+		f.Close()
+		return func() { os.Remove(lock) }, true, nil
+	}
+
+	if !errors.Is(err, fs.ErrExist) {
+		return nil, false, err
+	}
+
+	if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > timeout {
+		os.Remove(lock)
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,47 @@
+//go:build unix
+
+package notifications
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// tryLock 使用 flock 尝试获取 lock 的排他锁，锁被其他进程持有时返回 false
+// 持有锁的进程退出时由内核释放锁，不需要锁超时，timeout 不使用
+//
+// unlock 先删除锁文件再释放锁，获取锁后须检查锁文件未被删除或替换，
+// 否则等待同一文件的进程可能与新建锁文件的进程同时持有锁
+func tryLock(lock string, _ time.Duration) (unlock func(), ok bool, err error) {
+	f, err := os.OpenFile(lock, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	held, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+
+	// 锁文件已被持有者删除，重新获取
+	if current, err := os.Stat(lock); err != nil || !os.SameFile(held, current) {
+		f.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		os.Remove(lock)
+		f.Close()
+	}, true, nil
+}
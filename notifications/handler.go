@@ -0,0 +1,158 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+// 默认请求 body 大小限制
+const defaultMaxBodySize = 1 << 20
+
+// Notification 已验证并解码的 App Store Server Notifications V2 通知
+type Notification struct {
+	appstoreserverapi.NotificationV2
+
+	// Transaction data.signedTransactionInfo 解码后的交易信息，通知中不包含时为 nil
+	Transaction *appstoreserverapi.Transaction
+	// RenewalInfo data.signedRenewalInfo 解码后的续订信息，通知中不包含时为 nil
+	RenewalInfo *appstoreserverapi.RenewalInfo
+}
+
+// Decode 使用 jws.DefaultVerifier 验证并解码 signedPayload，包括其中嵌套的 signedTransactionInfo 和 signedRenewalInfo
+func Decode(signedPayload appstoreserverapi.JWSNotification) (*Notification, error) {
+	return DecodeWith(nil, signedPayload)
+}
+
+// DecodeWith 同 Decode，使用 verifier 验证并断言通知及嵌套的交易、续订信息属于当前 app 和环境
+// verifier 为 nil 时同 Decode
+func DecodeWith(verifier *appstoreserverapi.SignedDataVerifier, signedPayload appstoreserverapi.JWSNotification) (*Notification, error) {
+	if verifier == nil {
+		verifier = appstoreserverapi.NewSignedDataVerifier("", 0, "", nil)
+	}
+
+	notification, err := verifier.Notification(signedPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Notification{NotificationV2: *notification}
+	if v := notification.Data.SignedTransactionInfo; v != "" {
+		if out.Transaction, err = verifier.Transaction(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := notification.Data.SignedRenewalInfo; v != "" {
+		if out.RenewalInfo, err = verifier.RenewalInfo(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// HandlerFunc 处理已解码的通知，返回 error 时 Handler 响应非 200，App Store 会稍后重试
+type HandlerFunc func(ctx context.Context, n *Notification) error
+
+// Option Handler 可选配置
+type Option func(h *Handler)
+
+// WithMaxBodySize 设置请求 body 大小限制，默认 1MB
+func WithMaxBodySize(size int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = size
+	}
+}
+
+// WithErrorHandler 设置错误回调，用于记录处理失败的通知
+func WithErrorHandler(fn func(r *http.Request, err error)) Option {
+	return func(h *Handler) {
+		h.onError = fn
+	}
+}
+
+// WithVerifier 设置验证通知使用的 SignedDataVerifier，通常使用 Service.SignedDataVerifier()
+func WithVerifier(verifier *appstoreserverapi.SignedDataVerifier) Option {
+	return func(h *Handler) {
+		h.verifier = verifier
+	}
+}
+
+// Handler 接收 App Store Server Notifications V2 的 http.Handler
+// 文档：https://developer.apple.com/documentation/appstoreservernotifications/receiving_app_store_server_notifications
+//
+// 仅当 HandlerFunc 处理成功时响应 200，其他情况 App Store 会按其重试策略重新发送通知
+type Handler struct {
+	fn          HandlerFunc
+	maxBodySize int64
+	onError     func(r *http.Request, err error)
+	verifier    *appstoreserverapi.SignedDataVerifier
+}
+
+func NewHandler(fn HandlerFunc, opts ...Option) *Handler {
+	h := &Handler{
+		fn:          fn,
+		maxBodySize: defaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// responseBodyV2 https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2
+type responseBodyV2 struct {
+	SignedPayload appstoreserverapi.JWSNotification `json:"signedPayload"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		h.fail(w, r, http.StatusMethodNotAllowed, errors.New("appstore.notifications.Handler: method not allowed"))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var payload responseBodyV2
+	if err := json.Unmarshal(body, &payload); err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if payload.SignedPayload == "" {
+		h.fail(w, r, http.StatusBadRequest, errors.New("appstore.notifications.Handler: empty signedPayload"))
+		return
+	}
+
+	n, err := DecodeWith(h.verifier, payload.SignedPayload)
+	if err != nil {
+		h.fail(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.fn(r.Context(), n); err != nil {
+		h.fail(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, code int, err error) {
+	if h.onError != nil {
+		h.onError(r, err)
+	}
+
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,111 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+	"github.com/beanscc/appstore/jws"
+	"github.com/beanscc/appstore/jws/jwstest"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	called := false
+	h := NewHandler(func(ctx context.Context, n *Notification) error {
+		called = true
+		return nil
+	}, WithMaxBodySize(64))
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{name: "method", method: http.MethodGet, body: ``, want: http.StatusMethodNotAllowed},
+		{name: "invalid json", method: http.MethodPost, body: `{`, want: http.StatusBadRequest},
+		{name: "empty payload", method: http.MethodPost, body: `{}`, want: http.StatusBadRequest},
+		{name: "invalid jws", method: http.MethodPost, body: `{"signedPayload":"a.b"}`, want: http.StatusBadRequest},
+		{name: "too large", method: http.MethodPost, body: `{"signedPayload":"` + strings.Repeat("a", 64) + `"}`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, "/notifications", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("TestHandler_ServeHTTP %s: got code:%d, want:%d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	if called {
+		t.Errorf("TestHandler_ServeHTTP: HandlerFunc should not be called for invalid requests")
+	}
+}
+
+func TestHandler_ServeHTTP_Verified(t *testing.T) {
+	signer, err := jwstest.NewSigner()
+	if err != nil {
+		t.Fatalf("jwstest.NewSigner failed. err:%v", err)
+	}
+
+	sign := func(payload interface{}) string {
+		token, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatalf("signer.Sign failed. err:%v", err)
+		}
+		return token
+	}
+
+	body := func(bundleID string) string {
+		tx := appstoreserverapi.Transaction{TransactionID: "1000", BundleID: bundleID, Environment: appstoreserverapi.EnvironmentSandbox}
+		n := appstoreserverapi.NotificationV2{
+			NotificationType: appstoreserverapi.NotificationV2TypeDidRenew,
+			NotificationUUID: "uuid",
+			Data: appstoreserverapi.NotificationV2Data{
+				BundleID:              "com.example",
+				Environment:           appstoreserverapi.EnvironmentSandbox,
+				SignedTransactionInfo: appstoreserverapi.JWSTransaction(sign(tx)),
+			},
+		}
+		return `{"signedPayload":"` + sign(n) + `"}`
+	}
+
+	verifier := appstoreserverapi.NewSignedDataVerifier("com.example", 0, appstoreserverapi.EnvironmentSandbox,
+		jws.NewVerifier(jws.WithRoots(signer.Roots())))
+
+	var got *Notification
+	fail := false
+	h := NewHandler(func(ctx context.Context, n *Notification) error {
+		got = n
+		if fail {
+			return errors.New("failed")
+		}
+		return nil
+	}, WithVerifier(verifier))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body("com.example"))))
+	if w.Code != http.StatusOK || got == nil || got.Transaction == nil || got.Transaction.TransactionID != "1000" {
+		t.Errorf("TestHandler_ServeHTTP_Verified got code:%d, notification:%#v", w.Code, got)
+	}
+
+	// 嵌套交易的 bundleId 不匹配
+	got = nil
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body("com.other"))))
+	if w.Code != http.StatusBadRequest || got != nil {
+		t.Errorf("TestHandler_ServeHTTP_Verified bundle mismatch got code:%d", w.Code)
+	}
+
+	// 回调失败时响应非 200，App Store 会重试
+	fail = true
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body("com.example"))))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("TestHandler_ServeHTTP_Verified callback failed got code:%d", w.Code)
+	}
+}
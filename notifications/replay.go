@@ -0,0 +1,259 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+// maxHistoryAge App Store 仅保留最近 180 天的通知历史
+const maxHistoryAge = 180 * 24 * time.Hour
+
+// HistoryFetcher 获取通知历史，*appstoreserverapi.Service 实现了该接口
+type HistoryFetcher interface {
+	GetNotificationHistory(ctx context.Context, req *appstoreserverapi.GetNotificationHistoryReq, paginationToken string) (*appstoreserverapi.GetNotificationHistoryResp, error)
+}
+
+// Checkpoint 重放进度，中断后从该位置继续
+type Checkpoint struct {
+	// 本次重放的时间范围，时间范围不一致的 Checkpoint 会被忽略
+	RangeStartDate int64 `json:"rangeStartDate"`
+	RangeEndDate   int64 `json:"rangeEndDate"`
+
+	// 当前分段的开始时间
+	StartDate int64 `json:"startDate"`
+	// 当前分段的结束时间，PaginationToken 只对 [StartDate, EndDate] 的请求有效
+	EndDate int64 `json:"endDate"`
+	// 当前分段下一页的 paginationToken
+	PaginationToken string `json:"paginationToken"`
+	// 已重放完成，RangeEndDate 为 0 时 StartDate 为已重放到的时间，下次重放从该时间继续
+	Done bool `json:"done"`
+}
+
+// CheckpointStore 保存重放进度
+type CheckpointStore interface {
+	// Load 返回保存的进度，不存在时返回 nil
+	Load(ctx context.Context) (*Checkpoint, error)
+	Save(ctx context.Context, cp *Checkpoint) error
+}
+
+// MemoryCheckpoint 基于内存的 CheckpointStore
+type MemoryCheckpoint struct {
+	mutex sync.Mutex
+	cp    *Checkpoint
+}
+
+func (m *MemoryCheckpoint) Load(ctx context.Context) (*Checkpoint, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.cp == nil {
+		return nil, nil
+	}
+
+	cp := *m.cp
+	return &cp, nil
+}
+
+func (m *MemoryCheckpoint) Save(ctx context.Context, cp *Checkpoint) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	v := *cp
+	m.cp = &v
+	return nil
+}
+
+// FileCheckpoint 基于文件的 CheckpointStore
+type FileCheckpoint struct {
+	path string
+}
+
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{path: path}
+}
+
+func (f *FileCheckpoint) Load(ctx context.Context) (*Checkpoint, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+
+	return &cp, nil
+}
+
+func (f *FileCheckpoint) Save(ctx context.Context, cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path)
+}
+
+// ReplayOption Replayer 可选配置
+type ReplayOption func(r *Replayer)
+
+// WithCheckpoint 设置保存重放进度的 CheckpointStore，默认不保存进度
+func WithCheckpoint(store CheckpointStore) ReplayOption {
+	return func(r *Replayer) {
+		r.checkpoint = store
+	}
+}
+
+// WithWindow 设置每次请求的时间分段大小，默认且最大为 180 天
+func WithWindow(window time.Duration) ReplayOption {
+	return func(r *Replayer) {
+		if window > 0 && window < maxHistoryAge {
+			r.window = window
+		}
+	}
+}
+
+// WithReplayVerifier 设置验证通知使用的 SignedDataVerifier，参见 WithVerifier
+func WithReplayVerifier(verifier *appstoreserverapi.SignedDataVerifier) ReplayOption {
+	return func(r *Replayer) {
+		r.decode = func(signedPayload appstoreserverapi.JWSNotification) (*Notification, error) {
+			return DecodeWith(verifier, signedPayload)
+		}
+	}
+}
+
+// Replayer 通过 GetNotificationHistory 重放通知，用于补偿未成功接收的通知
+//   - 时间范围按 window 分段请求，开始时间早于 180 天前时从 180 天前开始
+//   - 自动翻页
+//   - 每页处理完成后保存进度，中断后再次调用 Replay 会从保存的位置继续
+//
+// 中断时当前页会被重新处理，回调应配合 Dedup 使用
+type Replayer struct {
+	fetcher    HistoryFetcher
+	checkpoint CheckpointStore
+	window     time.Duration
+
+	now    func() time.Time
+	decode func(signedPayload appstoreserverapi.JWSNotification) (*Notification, error)
+}
+
+func NewReplayer(fetcher HistoryFetcher, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		fetcher: fetcher,
+		window:  maxHistoryAge,
+		now:     time.Now,
+		decode:  Decode,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Replay 重放 req 时间范围内的通知，req.EndDate 为 0 时使用当前时间
+// req.EndDate 为 0 的重放完成后，使用相同 CheckpointStore 再次调用会继续重放此后新产生的通知
+func (r *Replayer) Replay(ctx context.Context, req *appstoreserverapi.GetNotificationHistoryReq, fn HandlerFunc) error {
+	now := r.now()
+	start, end := req.StartDate, req.EndDate
+	if end <= 0 {
+		end = now.UnixMilli()
+	}
+	if earliest := now.Add(-maxHistoryAge).UnixMilli(); start < earliest {
+		start = earliest
+	}
+	if start >= end {
+		return fmt.Errorf("appstore.notifications.Replayer: invalid date range, start:%d, end:%d", start, end)
+	}
+
+	cp := &Checkpoint{RangeStartDate: req.StartDate, RangeEndDate: req.EndDate, StartDate: start}
+	if r.checkpoint != nil {
+		saved, err := r.checkpoint.Load(ctx)
+		if err != nil {
+			return err
+		}
+
+		if saved != nil && saved.RangeStartDate == req.StartDate && saved.RangeEndDate == req.EndDate {
+			if saved.Done && req.EndDate > 0 {
+				return nil
+			}
+
+			// 开始时间被限制在 180 天内时，保存的分段已失效，从 start 重新开始
+			if saved.StartDate >= start {
+				cp.StartDate, cp.EndDate, cp.PaginationToken = saved.StartDate, saved.EndDate, saved.PaginationToken
+			}
+		}
+	}
+
+	for cp.StartDate < end {
+		chunk := *req
+		chunk.StartDate = cp.StartDate
+		chunk.EndDate = cp.StartDate + r.window.Milliseconds()
+		if chunk.EndDate > end {
+			chunk.EndDate = end
+		}
+		// paginationToken 须与生成它的请求一致，分段变化时重新请求该分段
+		if cp.PaginationToken != "" && cp.EndDate != chunk.EndDate {
+			cp.PaginationToken = ""
+		}
+		cp.EndDate = chunk.EndDate
+
+		resp, err := r.fetcher.GetNotificationHistory(ctx, &chunk, cp.PaginationToken)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range resp.NotificationHistory {
+			n, err := r.decode(item.SignedPayload)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(ctx, n); err != nil {
+				return err
+			}
+		}
+
+		if resp.HasMore {
+			// 没有 paginationToken 时会重复请求第一页
+			if resp.PaginationToken == "" {
+				return fmt.Errorf("appstore.notifications.Replayer: hasMore without paginationToken, start:%d, end:%d", chunk.StartDate, chunk.EndDate)
+			}
+			cp.PaginationToken = resp.PaginationToken
+		} else {
+			cp.StartDate, cp.PaginationToken = chunk.EndDate, ""
+		}
+
+		if err := r.save(ctx, cp); err != nil {
+			return err
+		}
+	}
+
+	cp.Done = true
+	return r.save(ctx, cp)
+}
+
+func (r *Replayer) save(ctx context.Context, cp *Checkpoint) error {
+	if r.checkpoint == nil {
+		return nil
+	}
+
+	return r.checkpoint.Save(ctx, cp)
+}
@@ -0,0 +1,213 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+// testHistoryFetcher 每个时间分段返回 pages 页，每页 1 条通知，signedPayload 为 "startDate-page"
+type testHistoryFetcher struct {
+	pages    int
+	requests []string
+	failAt   int
+	// 返回 hasMore 但不返回 paginationToken
+	noToken bool
+}
+
+func (f *testHistoryFetcher) GetNotificationHistory(ctx context.Context, req *appstoreserverapi.GetNotificationHistoryReq, paginationToken string) (*appstoreserverapi.GetNotificationHistoryResp, error) {
+	f.requests = append(f.requests, strconv.FormatInt(req.StartDate, 10)+"-"+strconv.FormatInt(req.EndDate, 10)+"-"+paginationToken)
+	if f.failAt > 0 && len(f.requests) == f.failAt {
+		return nil, errors.New("fetch failed")
+	}
+
+	page := 0
+	if paginationToken != "" {
+		page, _ = strconv.Atoi(paginationToken)
+	}
+
+	resp := &appstoreserverapi.GetNotificationHistoryResp{
+		HasMore: page+1 < f.pages,
+		NotificationHistory: []appstoreserverapi.NotificationHistoryItem{
+			{SignedPayload: appstoreserverapi.JWSNotification(strconv.FormatInt(req.StartDate, 10) + "-" + strconv.Itoa(page))},
+		},
+	}
+	if resp.HasMore && !f.noToken {
+		resp.PaginationToken = strconv.Itoa(page + 1)
+	}
+
+	return resp, nil
+}
+
+func testReplayer(fetcher HistoryFetcher, now time.Time, opts ...ReplayOption) *Replayer {
+	r := NewReplayer(fetcher, opts...)
+	r.now = func() time.Time { return now }
+	r.decode = func(signedPayload appstoreserverapi.JWSNotification) (*Notification, error) {
+		return testNotification(string(signedPayload)), nil
+	}
+	return r
+}
+
+func TestReplayer_Replay(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	fetcher := &testHistoryFetcher{pages: 2}
+	r := testReplayer(fetcher, now, WithWindow(10*day))
+
+	var got []string
+	req := &appstoreserverapi.GetNotificationHistoryReq{
+		StartDate: now.Add(-200 * day).UnixMilli(),
+		EndDate:   now.Add(-165 * day).UnixMilli(),
+	}
+	err := r.Replay(context.Background(), req, func(ctx context.Context, n *Notification) error {
+		got = append(got, n.NotificationUUID)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("TestReplayer_Replay failed. err:%v", err)
+		return
+	}
+
+	// 开始时间被限制在 180 天内，15 天按 10 天分段为 2 段，每段 2 页
+	start := now.Add(-180 * day).UnixMilli()
+	second := start + (10 * day).Milliseconds()
+	want := []string{
+		strconv.FormatInt(start, 10) + "-0",
+		strconv.FormatInt(start, 10) + "-1",
+		strconv.FormatInt(second, 10) + "-0",
+		strconv.FormatInt(second, 10) + "-1",
+	}
+	if len(got) != len(want) {
+		t.Errorf("TestReplayer_Replay got:%v, want:%v", got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TestReplayer_Replay got:%v, want:%v", got, want)
+			return
+		}
+	}
+}
+
+func TestReplayer_Resume(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	checkpoint := NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"))
+	req := &appstoreserverapi.GetNotificationHistoryReq{
+		StartDate: now.Add(-20 * day).UnixMilli(),
+		EndDate:   now.UnixMilli(),
+	}
+
+	var got []string
+	fn := func(ctx context.Context, n *Notification) error {
+		got = append(got, n.NotificationUUID)
+		return nil
+	}
+
+	// 第 3 次请求失败，前 2 页已处理
+	fetcher := &testHistoryFetcher{pages: 2, failAt: 3}
+	if err := testReplayer(fetcher, now, WithWindow(10*day), WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err == nil {
+		t.Errorf("TestReplayer_Resume: want error")
+		return
+	}
+
+	fetcher = &testHistoryFetcher{pages: 2}
+	if err := testReplayer(fetcher, now, WithWindow(10*day), WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err != nil {
+		t.Errorf("TestReplayer_Resume failed. err:%v", err)
+		return
+	}
+
+	if len(got) != 4 || len(fetcher.requests) != 2 {
+		t.Errorf("TestReplayer_Resume got:%v, requests:%v", got, fetcher.requests)
+	}
+
+	// 已完成的重放不再请求
+	fetcher = &testHistoryFetcher{pages: 2}
+	if err := testReplayer(fetcher, now, WithWindow(10*day), WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err != nil || len(fetcher.requests) != 0 {
+		t.Errorf("TestReplayer_Resume done got requests:%v, err:%v", fetcher.requests, err)
+	}
+}
+
+func TestReplayer_ResumeChangedChunk(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	checkpoint := &MemoryCheckpoint{}
+	// EndDate 为 0，每次重放的结束时间为当前时间
+	req := &appstoreserverapi.GetNotificationHistoryReq{StartDate: now.Add(-5 * day).UnixMilli()}
+	fn := func(ctx context.Context, n *Notification) error { return nil }
+
+	fetcher := &testHistoryFetcher{pages: 3, failAt: 2}
+	if err := testReplayer(fetcher, now, WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err == nil {
+		t.Errorf("TestReplayer_ResumeChangedChunk: want error")
+		return
+	}
+
+	// 结束时间变化，保存的 paginationToken 不再有效，重新请求该分段
+	fetcher = &testHistoryFetcher{pages: 3}
+	later := now.Add(time.Hour)
+	if err := testReplayer(fetcher, later, WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err != nil {
+		t.Errorf("TestReplayer_ResumeChangedChunk failed. err:%v", err)
+		return
+	}
+
+	want := strconv.FormatInt(req.StartDate, 10) + "-" + strconv.FormatInt(later.UnixMilli(), 10) + "-"
+	if len(fetcher.requests) != 3 || fetcher.requests[0] != want {
+		t.Errorf("TestReplayer_ResumeChangedChunk got requests:%v, want first:%s", fetcher.requests, want)
+	}
+
+	// 开始时间被限制在 180 天内，保存的分段失效
+	checkpoint = &MemoryCheckpoint{}
+	old := &appstoreserverapi.GetNotificationHistoryReq{StartDate: 1, EndDate: now.UnixMilli()}
+	checkpoint.Save(context.Background(), &Checkpoint{RangeStartDate: 1, RangeEndDate: old.EndDate, StartDate: now.Add(-200 * day).UnixMilli(), EndDate: now.Add(-20 * day).UnixMilli(), PaginationToken: "1"})
+	fetcher = &testHistoryFetcher{pages: 1}
+	if err := testReplayer(fetcher, now, WithCheckpoint(checkpoint)).Replay(context.Background(), old, fn); err != nil {
+		t.Errorf("TestReplayer_ResumeChangedChunk failed. err:%v", err)
+		return
+	}
+	want = strconv.FormatInt(now.Add(-maxHistoryAge).UnixMilli(), 10) + "-" + strconv.FormatInt(now.UnixMilli(), 10) + "-"
+	if len(fetcher.requests) != 1 || fetcher.requests[0] != want {
+		t.Errorf("TestReplayer_ResumeChangedChunk got requests:%v, want:%s", fetcher.requests, want)
+	}
+}
+
+func TestReplayer_ResumeOpenEnded(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	checkpoint := &MemoryCheckpoint{}
+	req := &appstoreserverapi.GetNotificationHistoryReq{StartDate: now.Add(-time.Hour).UnixMilli()}
+	fn := func(ctx context.Context, n *Notification) error { return nil }
+
+	fetcher := &testHistoryFetcher{pages: 1}
+	if err := testReplayer(fetcher, now, WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err != nil {
+		t.Errorf("TestReplayer_ResumeOpenEnded failed. err:%v", err)
+		return
+	}
+
+	// 结束时间为当前时间的重放完成后，再次重放从上次的结束时间继续
+	fetcher = &testHistoryFetcher{pages: 1}
+	later := now.Add(time.Hour)
+	if err := testReplayer(fetcher, later, WithCheckpoint(checkpoint)).Replay(context.Background(), req, fn); err != nil {
+		t.Errorf("TestReplayer_ResumeOpenEnded failed. err:%v", err)
+		return
+	}
+
+	want := strconv.FormatInt(now.UnixMilli(), 10) + "-" + strconv.FormatInt(later.UnixMilli(), 10) + "-"
+	if len(fetcher.requests) != 1 || fetcher.requests[0] != want {
+		t.Errorf("TestReplayer_ResumeOpenEnded got requests:%v, want:%s", fetcher.requests, want)
+	}
+}
+
+func TestReplayer_MissingPaginationToken(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	req := &appstoreserverapi.GetNotificationHistoryReq{StartDate: now.Add(-time.Hour).UnixMilli()}
+	fn := func(ctx context.Context, n *Notification) error { return nil }
+
+	fetcher := &testHistoryFetcher{pages: 2, noToken: true}
+	if err := testReplayer(fetcher, now).Replay(context.Background(), req, fn); err == nil || len(fetcher.requests) != 1 {
+		t.Errorf("TestReplayer_MissingPaginationToken got requests:%v, err:%v", fetcher.requests, err)
+	}
+}
@@ -0,0 +1,84 @@
+package notifications
+
+import (
+	"context"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+type route struct {
+	typ     appstoreserverapi.NotificationV2Type
+	subtype appstoreserverapi.NotificationV2Subtype
+}
+
+// Router 按 notificationType 和 subtype 分发通知
+//
+// 匹配顺序：
+//   - On 注册的 type + subtype
+//   - OnType 注册的 type（匹配该 type 的任意 subtype）
+//   - Fallback
+//
+// 未定义的通知类型优先交由 Unknown 处理，未设置时交由 Fallback 处理。
+// 没有匹配的 handler 时通知被视为处理成功，避免 App Store 无意义的重试。
+// Router 的注册方法不是并发安全的，应在开始接收通知前完成注册
+type Router struct {
+	routes   map[route]HandlerFunc
+	fallback HandlerFunc
+	unknown  HandlerFunc
+}
+
+func NewRouter() *Router {
+	return &Router{
+		routes: make(map[route]HandlerFunc),
+	}
+}
+
+// On 注册指定 type 和 subtype 的 handler
+func (r *Router) On(typ appstoreserverapi.NotificationV2Type, subtype appstoreserverapi.NotificationV2Subtype, fn HandlerFunc) *Router {
+	r.routes[route{typ: typ, subtype: subtype}] = fn
+	return r
+}
+
+// OnType 注册指定 type 的 handler，匹配该 type 下所有未单独注册的 subtype
+func (r *Router) OnType(typ appstoreserverapi.NotificationV2Type, fn HandlerFunc) *Router {
+	return r.On(typ, "", fn)
+}
+
+// Fallback 注册没有匹配到 handler 时使用的 handler
+func (r *Router) Fallback(fn HandlerFunc) *Router {
+	r.fallback = fn
+	return r
+}
+
+// Unknown 注册未定义的通知类型使用的 handler
+func (r *Router) Unknown(fn HandlerFunc) *Router {
+	r.unknown = fn
+	return r
+}
+
+// Dispatch 分发通知到匹配的 handler，可直接作为 HandlerFunc 使用
+//
+//	notifications.NewHandler(router.Dispatch)
+func (r *Router) Dispatch(ctx context.Context, n *Notification) error {
+	if fn := r.match(n); fn != nil {
+		return fn(ctx, n)
+	}
+
+	return nil
+}
+
+func (r *Router) match(n *Notification) HandlerFunc {
+	if !n.NotificationType.Known() && r.unknown != nil {
+		return r.unknown
+	}
+
+	if fn, ok := r.routes[route{typ: n.NotificationType, subtype: n.Subtype}]; ok {
+		return fn
+	}
+
+	if fn, ok := r.routes[route{typ: n.NotificationType}]; ok {
+		return fn
+	}
+
+	return r.fallback
+}
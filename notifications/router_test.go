@@ -0,0 +1,56 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+func TestRouter_Dispatch(t *testing.T) {
+	var got string
+	handle := func(name string) HandlerFunc {
+		return func(ctx context.Context, n *Notification) error {
+			got = name
+			return nil
+		}
+	}
+
+	router := NewRouter().
+		OnType(appstoreserverapi.NotificationV2TypeSubscribed, handle("subscribed")).
+		On(appstoreserverapi.NotificationV2TypeSubscribed, appstoreserverapi.NotificationV2SubtypeResubscribe, handle("resubscribe")).
+		OnType(appstoreserverapi.NotificationV2TypeDidRenew, handle("did_renew")).
+		Fallback(handle("fallback")).
+		Unknown(handle("unknown"))
+
+	tests := []struct {
+		typ     appstoreserverapi.NotificationV2Type
+		subtype appstoreserverapi.NotificationV2Subtype
+		want    string
+	}{
+		{typ: appstoreserverapi.NotificationV2TypeSubscribed, subtype: appstoreserverapi.NotificationV2SubtypeResubscribe, want: "resubscribe"},
+		{typ: appstoreserverapi.NotificationV2TypeSubscribed, subtype: appstoreserverapi.NotificationV2SubtypeInitialBuy, want: "subscribed"},
+		{typ: appstoreserverapi.NotificationV2TypeDidRenew, want: "did_renew"},
+		{typ: appstoreserverapi.NotificationV2TypeRefund, want: "fallback"},
+		{typ: "NEW_TYPE", want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		got = ""
+		n := &Notification{NotificationV2: appstoreserverapi.NotificationV2{NotificationType: tt.typ, Subtype: tt.subtype}}
+		if err := router.Dispatch(context.Background(), n); err != nil {
+			t.Errorf("TestRouter_Dispatch failed. err:%v", err)
+			return
+		}
+
+		if got != tt.want {
+			t.Errorf("TestRouter_Dispatch type:%s, subtype:%s, got:%s, want:%s", tt.typ, tt.subtype, got, tt.want)
+		}
+	}
+
+	// 未注册任何 handler 时视为处理成功
+	n := &Notification{NotificationV2: appstoreserverapi.NotificationV2{NotificationType: appstoreserverapi.NotificationV2TypeRefund}}
+	if err := NewRouter().Dispatch(context.Background(), n); err != nil {
+		t.Errorf("TestRouter_Dispatch empty router failed. err:%v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package pkcs7
+
+import (
+	"errors"
+)
+
+// 嵌套层数上限，避免恶意数据导致栈溢出
+const maxBERDepth = 64
+
+var errBERTruncated = errors.New("appstore.pkcs7: ber: truncated data")
+
+// berToDER 将 BER 编码转换为 DER 编码
+//   - 不定长编码改为定长编码
+//   - 构造类型的 OCTET STRING 合并为基本类型
+//
+// App Store 收据的外层 ContentInfo 使用不定长编码，encoding/asn1 只支持 DER
+func berToDER(ber []byte) ([]byte, error) {
+	tag, content, rest, err := parseBER(ber, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) != 0 {
+		return nil, errors.New("appstore.pkcs7: ber: trailing data")
+	}
+
+	return encodeDER(tag, content), nil
+}
+
+// parseBER 解析 ber 中的第一个元素，返回 DER 格式的 tag 及内容
+func parseBER(ber []byte, depth int) (tag []byte, content []byte, rest []byte, err error) {
+	if depth > maxBERDepth {
+		return nil, nil, nil, errors.New("appstore.pkcs7: ber: too deeply nested")
+	}
+
+	if len(ber) < 2 {
+		return nil, nil, nil, errBERTruncated
+	}
+
+	// identifier octets
+	n := 1
+	if ber[0]&0x1f == 0x1f {
+		for {
+			if n >= len(ber) {
+				return nil, nil, nil, errBERTruncated
+			}
+			n++
+			if ber[n-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	tag, ber = ber[:n], ber[n:]
+	constructed := tag[0]&0x20 != 0
+
+	// length octets
+	if len(ber) == 0 {
+		return nil, nil, nil, errBERTruncated
+	}
+	indefinite := ber[0] == 0x80
+	var length int
+	switch {
+	case indefinite:
+		if !constructed {
+			return nil, nil, nil, errors.New("appstore.pkcs7: ber: indefinite length for primitive type")
+		}
+		ber = ber[1:]
+	case ber[0] < 0x80:
+		length, ber = int(ber[0]), ber[1:]
+	default:
+		size := int(ber[0] & 0x7f)
+		if size > 4 || size >= len(ber) {
+			return nil, nil, nil, errors.New("appstore.pkcs7: ber: invalid length")
+		}
+		for _, b := range ber[1 : 1+size] {
+			length = length<<8 | int(b)
+		}
+		ber = ber[1+size:]
+	}
+
+	if !indefinite && length > len(ber) {
+		return nil, nil, nil, errBERTruncated
+	}
+
+	if !constructed {
+		return tag, ber[:length], ber[length:], nil
+	}
+
+	children := ber
+	if !indefinite {
+		children, rest = ber[:length], ber[length:]
+	}
+
+	// OCTET STRING 的构造类型编码，合并所有分段
+	octetString := len(tag) == 1 && tag[0] == 0x24
+	for {
+		if indefinite {
+			if len(children) < 2 {
+				return nil, nil, nil, errBERTruncated
+			}
+			if children[0] == 0 && children[1] == 0 {
+				rest = children[2:]
+				break
+			}
+		} else if len(children) == 0 {
+			break
+		}
+
+		childTag, childContent, childRest, err := parseBER(children, depth+1)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		children = childRest
+
+		if octetString {
+			if len(childTag) != 1 || childTag[0] != 0x04 {
+				return nil, nil, nil, errors.New("appstore.pkcs7: ber: invalid constructed octet string")
+			}
+			content = append(content, childContent...)
+			continue
+		}
+		content = append(content, encodeDER(childTag, childContent)...)
+	}
+
+	if octetString {
+		tag = []byte{0x04}
+	}
+
+	return tag, content, rest, nil
+}
+
+// encodeDER 使用定长编码 tag 及内容
+func encodeDER(tag []byte, content []byte) []byte {
+	out := make([]byte, 0, len(tag)+5+len(content))
+	out = append(out, tag...)
+
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		var size []byte
+		for ; n > 0; n >>= 8 {
+			size = append([]byte{byte(n)}, size...)
+		}
+		out = append(out, 0x80|byte(len(size)))
+		out = append(out, size...)
+	}
+
+	return append(out, content...)
+}
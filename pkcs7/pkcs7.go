@@ -0,0 +1,318 @@
+// Package pkcs7 解析并验证 PKCS#7 (CMS) SignedData，用于 App Store 收据等 BER 编码的签名数据
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+var (
+	OIDData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+	oidAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+)
+
+var (
+	// ErrNoRoots 未配置可信根证书
+	ErrNoRoots = errors.New("appstore.pkcs7: no trusted roots")
+	// ErrNoSigners SignedData 不包含签名者信息
+	ErrNoSigners = errors.New("appstore.pkcs7: no signers")
+	// ErrSignerNotFound 签名者的证书不在 SignedData 的证书中
+	ErrSignerNotFound = errors.New("appstore.pkcs7: signer certificate not found")
+	// ErrUnsupportedAlgorithm 不支持的摘要或签名算法
+	ErrUnsupportedAlgorithm = errors.New("appstore.pkcs7: unsupported algorithm")
+)
+
+// https://datatracker.ietf.org/doc/html/rfc5652#section-3
+// Content 为 [0] EXPLICIT 编码，RawValue 保留外层的 [0]
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc5652#section-5.1
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// https://datatracker.ietf.org/doc/html/rfc5652#section-5.3
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerialNumber
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// SignedData 解析后的 PKCS#7 SignedData
+type SignedData struct {
+	// 被签名内容的类型，App Store 收据为 OIDData
+	ContentType asn1.ObjectIdentifier
+	// 被签名的内容
+	Content []byte
+	// SignedData 中携带的证书
+	Certificates []*x509.Certificate
+
+	signers []signerInfo
+}
+
+// Parse 解析 BER 或 DER 编码的 PKCS#7 ContentInfo，其内容须为 SignedData
+// Parse 不验证签名，参见 SignedData.Verify
+func Parse(data []byte) (*SignedData, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var info contentInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("appstore.pkcs7: parse content info: %w", err)
+	} else if len(rest) != 0 {
+		return nil, errors.New("appstore.pkcs7: trailing data after content info")
+	}
+
+	if !info.ContentType.Equal(OIDSignedData) {
+		return nil, fmt.Errorf("appstore.pkcs7: content type %s is not signed data", info.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("appstore.pkcs7: parse signed data: %w", err)
+	}
+
+	out := &SignedData{
+		ContentType: sd.ContentInfo.ContentType,
+		signers:     sd.SignerInfos,
+	}
+
+	if len(sd.ContentInfo.Content.Bytes) != 0 {
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &raw); err != nil {
+			return nil, fmt.Errorf("appstore.pkcs7: parse content: %w", err)
+		}
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagOctetString {
+			return nil, errors.New("appstore.pkcs7: content is not an octet string")
+		}
+		out.Content = raw.Bytes
+	}
+
+	if len(sd.Certificates.Bytes) != 0 {
+		if out.Certificates, err = x509.ParseCertificates(sd.Certificates.Bytes); err != nil {
+			return nil, fmt.Errorf("appstore.pkcs7: parse certificates: %w", err)
+		}
+	}
+
+	return out, nil
+}
+
+type verifyOptions struct {
+	roots       *x509.CertPool
+	currentTime time.Time
+}
+
+// VerifyOption SignedData.Verify 可选配置
+type VerifyOption func(o *verifyOptions)
+
+// WithRoots 设置验证签名者证书链的可信根证书，必须配置
+func WithRoots(roots *x509.CertPool) VerifyOption {
+	return func(o *verifyOptions) {
+		o.roots = roots
+	}
+}
+
+// WithVerificationTime 设置验证证书有效期的时间，默认为当前时间
+func WithVerificationTime(t time.Time) VerifyOption {
+	return func(o *verifyOptions) {
+		o.currentTime = t
+	}
+}
+
+// Verify 验证每个签名者的签名，及签名者证书到可信根证书的证书链
+func (sd *SignedData) Verify(opts ...VerifyOption) error {
+	var o verifyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.roots == nil {
+		return ErrNoRoots
+	}
+
+	if len(sd.signers) == 0 {
+		return ErrNoSigners
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range sd.Certificates {
+		intermediates.AddCert(cert)
+	}
+
+	for i := range sd.signers {
+		cert, err := sd.verifySignature(&sd.signers[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = cert.Verify(x509.VerifyOptions{
+			Roots:         o.roots,
+			Intermediates: intermediates,
+			CurrentTime:   o.currentTime,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return fmt.Errorf("appstore.pkcs7: verify certificate chain: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// verifySignature 验证单个签名者的签名，返回签名者证书
+func (sd *SignedData) verifySignature(signer *signerInfo) (*x509.Certificate, error) {
+	cert := sd.signerCertificate(signer)
+	if cert == nil {
+		return nil, ErrSignerNotFound
+	}
+
+	hash, algo, err := signatureAlgorithm(signer.DigestAlgorithm.Algorithm, signer.DigestEncryptionAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := sd.Content
+	if len(signer.AuthenticatedAttributes.FullBytes) != 0 {
+		digest, err := messageDigest(signer.AuthenticatedAttributes.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		h := hash.New()
+		h.Write(sd.Content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return nil, errors.New("appstore.pkcs7: message digest mismatch")
+		}
+
+		// 有签名属性时，签名的是 SET OF 编码（而非 [0] IMPLICIT）的签名属性
+		signed = append([]byte{0x31}, signer.AuthenticatedAttributes.FullBytes[1:]...)
+	}
+
+	if err := cert.CheckSignature(algo, signed, signer.EncryptedDigest); err != nil {
+		return nil, fmt.Errorf("appstore.pkcs7: verify signature: %w", err)
+	}
+
+	return cert, nil
+}
+
+func (sd *SignedData) signerCertificate(signer *signerInfo) *x509.Certificate {
+	for _, cert := range sd.Certificates {
+		if cert.SerialNumber.Cmp(signer.IssuerAndSerialNumber.SerialNumber) == 0 &&
+			bytes.Equal(cert.RawIssuer, signer.IssuerAndSerialNumber.Issuer.FullBytes) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// messageDigest 从签名属性中获取 messageDigest
+func messageDigest(attrs []byte) ([]byte, error) {
+	for len(attrs) != 0 {
+		var attr attribute
+		rest, err := asn1.Unmarshal(attrs, &attr)
+		if err != nil {
+			return nil, fmt.Errorf("appstore.pkcs7: parse signed attributes: %w", err)
+		}
+		attrs = rest
+
+		if attr.Type.Equal(oidAttributeMessageDigest) {
+			var digest []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+				return nil, fmt.Errorf("appstore.pkcs7: parse message digest: %w", err)
+			}
+			return digest, nil
+		}
+	}
+
+	return nil, errors.New("appstore.pkcs7: missing message digest attribute")
+}
+
+var (
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidEncryptionRSA       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEncryptionSHA1RSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidEncryptionSHA256RSA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidEncryptionSHA384RSA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidEncryptionSHA512RSA = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidEncryptionECDSA     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidEncryptionSHA1ECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}
+	oidEncryptionSHA2ECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3}
+)
+
+// signatureAlgorithm 根据摘要算法及签名算法确定 x509.SignatureAlgorithm
+func signatureAlgorithm(digest, encryption asn1.ObjectIdentifier) (crypto.Hash, x509.SignatureAlgorithm, error) {
+	var hash crypto.Hash
+	switch {
+	case digest.Equal(oidDigestSHA1):
+		hash = crypto.SHA1
+	case digest.Equal(oidDigestSHA256):
+		hash = crypto.SHA256
+	case digest.Equal(oidDigestSHA384):
+		hash = crypto.SHA384
+	case digest.Equal(oidDigestSHA512):
+		hash = crypto.SHA512
+	default:
+		return 0, 0, fmt.Errorf("%w: digest %s", ErrUnsupportedAlgorithm, digest)
+	}
+
+	rsa := map[crypto.Hash]x509.SignatureAlgorithm{
+		crypto.SHA1: x509.SHA1WithRSA, crypto.SHA256: x509.SHA256WithRSA,
+		crypto.SHA384: x509.SHA384WithRSA, crypto.SHA512: x509.SHA512WithRSA,
+	}
+	ecdsa := map[crypto.Hash]x509.SignatureAlgorithm{
+		crypto.SHA1: x509.ECDSAWithSHA1, crypto.SHA256: x509.ECDSAWithSHA256,
+		crypto.SHA384: x509.ECDSAWithSHA384, crypto.SHA512: x509.ECDSAWithSHA512,
+	}
+
+	switch {
+	case encryption.Equal(oidEncryptionRSA), encryption.Equal(oidEncryptionSHA1RSA), encryption.Equal(oidEncryptionSHA256RSA),
+		encryption.Equal(oidEncryptionSHA384RSA), encryption.Equal(oidEncryptionSHA512RSA):
+		return hash, rsa[hash], nil
+	case encryption.Equal(oidEncryptionECDSA), encryption.Equal(oidEncryptionSHA1ECDSA),
+		len(encryption) == len(oidEncryptionSHA2ECDSA)+1 && encryption[:len(oidEncryptionSHA2ECDSA)].Equal(oidEncryptionSHA2ECDSA):
+		return hash, ecdsa[hash], nil
+	}
+
+	return 0, 0, fmt.Errorf("%w: signature %s", ErrUnsupportedAlgorithm, encryption)
+}
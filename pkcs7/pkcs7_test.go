@@ -0,0 +1,88 @@
+package pkcs7
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/pkcs7/pkcs7test"
+)
+
+func TestBerToDER(t *testing.T) {
+	tests := []struct {
+		name string
+		ber  []byte
+		der  []byte
+	}{
+		{name: "definite", ber: []byte{0x30, 0x03, 0x02, 0x01, 0x05}, der: []byte{0x30, 0x03, 0x02, 0x01, 0x05}},
+		{name: "indefinite", ber: []byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00}, der: []byte{0x30, 0x03, 0x02, 0x01, 0x05}},
+		{
+			name: "constructed octet string",
+			ber:  []byte{0x30, 0x80, 0x24, 0x80, 0x04, 0x01, 'a', 0x04, 0x02, 'b', 'c', 0x00, 0x00, 0x00, 0x00},
+			der:  []byte{0x30, 0x05, 0x04, 0x03, 'a', 'b', 'c'},
+		},
+		{name: "long length", ber: append([]byte{0x04, 0x81, 0x80}, make([]byte, 128)...), der: append([]byte{0x04, 0x81, 0x80}, make([]byte, 128)...)},
+	}
+
+	for _, tt := range tests {
+		got, err := berToDER(tt.ber)
+		if err != nil || !bytes.Equal(got, tt.der) {
+			t.Errorf("TestBerToDER %s: got:%x, err:%v, want:%x", tt.name, got, err, tt.der)
+		}
+	}
+
+	for _, ber := range [][]byte{{0x30, 0x80, 0x02, 0x01, 0x05}, {0x04, 0x80, 0x00, 0x00}, {0x30, 0x05, 0x02, 0x01}} {
+		if _, err := berToDER(ber); err == nil {
+			t.Errorf("TestBerToDER %x: want error", ber)
+		}
+	}
+}
+
+func TestSignedData_Verify(t *testing.T) {
+	content := bytes.Repeat([]byte("receipt"), 50)
+	for _, opts := range [][]pkcs7test.Option{nil, {pkcs7test.WithSignedAttributes()}} {
+		signer, err := pkcs7test.NewSigner(opts...)
+		if err != nil {
+			t.Fatalf("pkcs7test.NewSigner failed. err:%v", err)
+		}
+
+		data, err := signer.Sign(content)
+		if err != nil {
+			t.Fatalf("signer.Sign failed. err:%v", err)
+		}
+
+		sd, err := Parse(data)
+		if err != nil {
+			t.Errorf("TestSignedData_Verify Parse failed. err:%v", err)
+			return
+		}
+
+		if !bytes.Equal(sd.Content, content) || !sd.ContentType.Equal(OIDData) || len(sd.Certificates) != 3 {
+			t.Errorf("TestSignedData_Verify got content type:%s, certificates:%d", sd.ContentType, len(sd.Certificates))
+		}
+
+		if err := sd.Verify(WithRoots(signer.Roots())); err != nil {
+			t.Errorf("TestSignedData_Verify failed. signed attributes:%v, err:%v", len(opts) != 0, err)
+		}
+
+		if err := sd.Verify(); !errors.Is(err, ErrNoRoots) {
+			t.Errorf("TestSignedData_Verify got err:%v, want ErrNoRoots", err)
+		}
+
+		if err := sd.Verify(WithRoots(signer.Roots()), WithVerificationTime(time.Now().Add(2*time.Hour))); err == nil {
+			t.Errorf("TestSignedData_Verify: expired certificate should be rejected")
+		}
+
+		other, _ := pkcs7test.NewSigner()
+		if err := sd.Verify(WithRoots(other.Roots())); err == nil {
+			t.Errorf("TestSignedData_Verify: untrusted root should be rejected")
+		}
+
+		sd.Content = append([]byte(nil), content...)
+		sd.Content[0] ^= 0xff
+		if err := sd.Verify(WithRoots(signer.Roots())); err == nil {
+			t.Errorf("TestSignedData_Verify: modified content should be rejected")
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package pkcs7test 生成用于测试的证书链及 App Store 收据格式（BER 不定长编码）的 PKCS#7 SignedData
+package pkcs7test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSA           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+)
+
+// Signer 持有 root -> intermediate -> leaf 证书链，使用 leaf 的 RSA 私钥签名
+type Signer struct {
+	Root         *x509.Certificate
+	Intermediate *x509.Certificate
+	Leaf         *x509.Certificate
+
+	RootKey         *ecdsa.PrivateKey
+	IntermediateKey *ecdsa.PrivateKey
+	LeafKey         *rsa.PrivateKey
+
+	signedAttributes bool
+}
+
+type options struct {
+	notBefore        time.Time
+	notAfter         time.Time
+	signedAttributes bool
+}
+
+// Option NewSigner 可选配置
+type Option func(o *options)
+
+// WithValidity 设置证书链的有效期，默认为当前时间前后 1 小时
+func WithValidity(notBefore, notAfter time.Time) Option {
+	return func(o *options) {
+		o.notBefore, o.notAfter = notBefore, notAfter
+	}
+}
+
+// WithSignedAttributes 签名时包含 contentType 及 messageDigest 签名属性
+func WithSignedAttributes() Option {
+	return func(o *options) {
+		o.signedAttributes = true
+	}
+}
+
+// NewSigner 生成 root -> intermediate -> leaf 证书链
+func NewSigner(opts ...Option) (*Signer, error) {
+	now := time.Now()
+	o := options{notBefore: now.Add(-time.Hour), notAfter: now.Add(time.Hour)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var (
+		s   = Signer{signedAttributes: o.signedAttributes}
+		err error
+	)
+	if s.RootKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	if s.IntermediateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	if s.LeafKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return nil, err
+	}
+
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             o.notBefore,
+		NotAfter:              o.notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if s.Root, err = create(root, root, &s.RootKey.PublicKey, s.RootKey); err != nil {
+		return nil, err
+	}
+
+	intermediate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "Test Intermediate CA"},
+		NotBefore:             o.notBefore,
+		NotAfter:              o.notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if s.Intermediate, err = create(intermediate, s.Root, &s.IntermediateKey.PublicKey, s.RootKey); err != nil {
+		return nil, err
+	}
+
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "Test Receipt Signing"},
+		NotBefore:    o.notBefore,
+		NotAfter:     o.notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if s.Leaf, err = create(leaf, s.Intermediate, &s.LeafKey.PublicKey, s.IntermediateKey); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func create(template, parent *x509.Certificate, pub, priv interface{}) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(der)
+}
+
+// Roots 返回只包含 Root 的证书池，用于 pkcs7.WithRoots
+func (s *Signer) Roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	return pool
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerialNumber
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type encapContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      encapContentInfo
+	Certificates     asn1.RawValue `asn1:"tag:0"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// Sign 使用 SHA256WithRSA 签名 content，返回 BER 编码的 ContentInfo
+// 外层 ContentInfo 及 content 的 OCTET STRING 使用不定长编码，与 App Store 收据相同
+func (s *Signer) Sign(content []byte) ([]byte, error) {
+	digest := sha256.Sum256(content)
+	signed := content
+
+	var attrs asn1.RawValue
+	if s.signedAttributes {
+		contentType, err := asn1.Marshal(oidData)
+		if err != nil {
+			return nil, err
+		}
+		messageDigest, err := asn1.Marshal(digest[:])
+		if err != nil {
+			return nil, err
+		}
+
+		// 签名 SET OF 编码的签名属性，SignerInfo 中为 [0] IMPLICIT 编码
+		if signed, err = asn1.MarshalWithParams([]attribute{
+			{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: contentType}}},
+			{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: messageDigest}}},
+		}, "set"); err != nil {
+			return nil, err
+		}
+		attrs.FullBytes = append([]byte{0xa0}, signed[1:]...)
+	}
+
+	h := sha256.Sum256(signed)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.LeafKey, crypto.SHA256, h[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []byte
+	for _, cert := range []*x509.Certificate{s.Leaf, s.Intermediate, s.Root} {
+		certs = append(certs, cert.Raw...)
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}},
+		ContentInfo: encapContentInfo{
+			ContentType: oidData,
+			Content:     asn1.RawValue{FullBytes: indefiniteOctetString(content)},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: s.Leaf.RawIssuer}, SerialNumber: s.Leaf.SerialNumber},
+			DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			AuthenticatedAttributes:   attrs,
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSA, Parameters: asn1.NullRawValue},
+			EncryptedDigest:           sig,
+		}},
+	}
+
+	body, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := asn1.Marshal(oidSignedData)
+	if err != nil {
+		return nil, err
+	}
+
+	// ContentInfo ::= SEQUENCE { contentType, [0] EXPLICIT content }，均使用不定长编码
+	out := append([]byte{0x30, 0x80}, oid...)
+	out = append(out, 0xa0, 0x80)
+	out = append(out, body...)
+	return append(out, 0, 0, 0, 0), nil
+}
+
+// indefiniteOctetString 将 content 编码为 [0] EXPLICIT 不定长、分段的构造类型 OCTET STRING
+func indefiniteOctetString(content []byte) []byte {
+	out := []byte{0xa0, 0x80, 0x24, 0x80}
+	for len(content) > 0 {
+		n := len(content)
+		if n > 100 {
+			n = 100
+		}
+
+		chunk, _ := asn1.Marshal(content[:n])
+		out = append(out, chunk...)
+		content = content[n:]
+	}
+	return append(out, 0, 0, 0, 0)
+}
@@ -0,0 +1,235 @@
+package receipt
+
+import (
+	"strconv"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+// Response verifyReceipt 的响应
+// https://developer.apple.com/documentation/appstorereceipts/responsebody
+type Response struct {
+	// The environment for which the receipt was generated. Possible values: Sandbox, Production
+	Environment appstoreserverapi.Environment `json:"environment"`
+	// An indicator that an error occurred during the request. 仅 status 为 21100-21199 时返回
+	IsRetryable bool `json:"is-retryable"`
+	// The latest Base64 encoded app receipt. Only returned for receipts that contain auto-renewable subscriptions
+	LatestReceipt string `json:"latest_receipt"`
+	// An array that contains all in-app purchase transactions. Only returned for receipts that contain auto-renewable subscriptions
+	LatestReceiptInfo []InApp `json:"latest_receipt_info"`
+	// An array where each element contains the pending renewal information for each auto-renewable subscription
+	PendingRenewalInfo []PendingRenewalInfo `json:"pending_renewal_info"`
+	// A JSON representation of the receipt that was sent for verification
+	Receipt Receipt `json:"receipt"`
+	// Either 0 if the receipt is valid, or a status code if there is an error, see Status
+	Status Status `json:"status"`
+}
+
+// Transactions 将收据中的交易转换为 appstoreserverapi.Transaction
+// 收据包含自动续期订阅时使用 latest_receipt_info，否则使用 receipt.in_app
+func (r *Response) Transactions() []appstoreserverapi.Transaction {
+	items := r.LatestReceiptInfo
+	if len(items) == 0 {
+		items = r.Receipt.InApp
+	}
+
+	out := make([]appstoreserverapi.Transaction, 0, len(items))
+	for i := range items {
+		out = append(out, items[i].Transaction(r.Receipt.BundleID, r.Environment))
+	}
+	return out
+}
+
+// RenewalInfos 将 pending_renewal_info 转换为 appstoreserverapi.RenewalInfo
+func (r *Response) RenewalInfos() []appstoreserverapi.RenewalInfo {
+	out := make([]appstoreserverapi.RenewalInfo, 0, len(r.PendingRenewalInfo))
+	for i := range r.PendingRenewalInfo {
+		out = append(out, r.PendingRenewalInfo[i].RenewalInfo(r.Environment))
+	}
+	return out
+}
+
+// Receipt The decoded version of the encoded receipt data sent with the request to the App Store
+// 日期字段只保留 UNIX 毫秒时间（*_ms），忽略格式化后的日期字符串
+// https://developer.apple.com/documentation/appstorereceipts/responsebody/receipt
+type Receipt struct {
+	// See app_item_id
+	AdamID int64 `json:"adam_id"`
+	// Generated by App Store Connect and used by the App Store to uniquely identify the app purchased. 0 in the sandbox
+	AppItemID int64 `json:"app_item_id"`
+	// The app’s version number, CFBundleVersion in iOS, CFBundleShortVersionString in macOS
+	ApplicationVersion string `json:"application_version"`
+	// The bundle identifier for the app to which the receipt belongs
+	BundleID string `json:"bundle_id"`
+	// A unique identifier for the app download transaction
+	DownloadID int64 `json:"download_id"`
+	// The time the receipt expires for apps purchased through the Volume Purchase Program, UNIX time in milliseconds
+	ExpirationDateMS string `json:"expiration_date_ms"`
+	// An array that contains the in-app purchase receipt fields for all in-app purchase transactions
+	InApp []InApp `json:"in_app"`
+	// The version of the app that the user originally purchased
+	OriginalApplicationVersion string `json:"original_application_version"`
+	// The time of the original app purchase, UNIX time in milliseconds
+	OriginalPurchaseDateMS string `json:"original_purchase_date_ms"`
+	// The time the user ordered the app available for pre-order, UNIX time in milliseconds
+	PreorderDateMS string `json:"preorder_date_ms"`
+	// The time the App Store generated the receipt, UNIX time in milliseconds
+	ReceiptCreationDateMS string `json:"receipt_creation_date_ms"`
+	// The type of receipt generated: Production, ProductionVPP, ProductionSandbox, ProductionVPPSandbox
+	ReceiptType string `json:"receipt_type"`
+	// The time the request to the verifyReceipt endpoint was processed, UNIX time in milliseconds
+	RequestDateMS string `json:"request_date_ms"`
+	// An arbitrary number that identifies a revision of your app. 0 in the sandbox
+	VersionExternalIdentifier int64 `json:"version_external_identifier"`
+}
+
+// InApp An array that contains the in-app purchase receipt fields for all in-app purchase transactions
+// latest_receipt_info 与 receipt.in_app 的元素字段相同
+// https://developer.apple.com/documentation/appstorereceipts/responsebody/receipt/in_app
+type InApp struct {
+	// A UUID that associates the transaction with a user on your own service
+	AppAccountToken string `json:"app_account_token"`
+	// The time the App Store refunded a transaction or revoked it from family sharing, UNIX time in milliseconds
+	CancellationDateMS string `json:"cancellation_date_ms"`
+	// The reason for a refunded or revoked transaction: "1" customer canceled due to an actual or perceived issue, "0" other reasons
+	CancellationReason string `json:"cancellation_reason"`
+	// The time a subscription expires or when it will renew, UNIX time in milliseconds
+	ExpiresDateMS string `json:"expires_date_ms"`
+	// A value that indicates whether the user is the purchaser of the product or is a family member with access to the product
+	InAppOwnershipType appstoreserverapi.InAppOwnershipType `json:"in_app_ownership_type"`
+	// An indicator of whether an auto-renewable subscription is in the introductory price period: "true" or "false"
+	IsInIntroOfferPeriod string `json:"is_in_intro_offer_period"`
+	// An indicator of whether a subscription is in the free trial period: "true" or "false"
+	IsTrialPeriod string `json:"is_trial_period"`
+	// An indicator that a subscription has been canceled due to an upgrade: "true" when present
+	IsUpgraded string `json:"is_upgraded"`
+	// The reference name of a subscription offer code that the customer redeemed
+	OfferCodeRefName string `json:"offer_code_ref_name"`
+	// The time of the original in-app purchase, UNIX time in milliseconds
+	OriginalPurchaseDateMS string `json:"original_purchase_date_ms"`
+	// The transaction identifier of the original purchase
+	OriginalTransactionID string `json:"original_transaction_id"`
+	// The unique identifier of the product purchased
+	ProductID string `json:"product_id"`
+	// The identifier of the subscription offer redeemed by the user
+	PromotionalOfferID string `json:"promotional_offer_id"`
+	// The time the App Store charged the user’s account for a purchase or renewal, UNIX time in milliseconds
+	PurchaseDateMS string `json:"purchase_date_ms"`
+	// The number of consumable products purchased
+	Quantity string `json:"quantity"`
+	// The identifier of the subscription group to which the subscription belongs
+	SubscriptionGroupIdentifier string `json:"subscription_group_identifier"`
+	// A unique identifier for a transaction such as a purchase, restore, or renewal
+	TransactionID string `json:"transaction_id"`
+	// A unique identifier for purchase events across devices, including subscription-renewal events
+	WebOrderLineItemID string `json:"web_order_line_item_id"`
+}
+
+// Transaction 转换为 appstoreserverapi.Transaction
+//   - 收据不包含商品类型，仅在包含 expires_date_ms 时设置为自动续期订阅
+//   - 免费试用、推介促销、促销优惠及优惠码分别映射为对应的 OfferType 和 OfferDiscountType
+func (i *InApp) Transaction(bundleID string, environment appstoreserverapi.Environment) appstoreserverapi.Transaction {
+	out := appstoreserverapi.Transaction{
+		AppAccountToken:             i.AppAccountToken,
+		BundleID:                    bundleID,
+		Environment:                 environment,
+		InAppOwnershipType:          i.InAppOwnershipType,
+		OriginalPurchaseDate:        parseInt(i.OriginalPurchaseDateMS),
+		OriginalTransactionID:       i.OriginalTransactionID,
+		ProductID:                   i.ProductID,
+		Quantity:                    int(parseInt(i.Quantity)),
+		TransactionID:               i.TransactionID,
+		PurchaseDate:                parseInt(i.PurchaseDateMS),
+		RevocationDate:              parseInt(i.CancellationDateMS),
+		SubscriptionGroupIdentifier: i.SubscriptionGroupIdentifier,
+		IsUpgraded:                  i.IsUpgraded == "true",
+		ExpiresDate:                 parseInt(i.ExpiresDateMS),
+		WebOrderLineItemID:          i.WebOrderLineItemID,
+	}
+
+	if i.CancellationReason != "" {
+		reason := int(parseInt(i.CancellationReason))
+		out.RevocationReason = &reason
+	}
+
+	if out.ExpiresDate != 0 {
+		out.Type = appstoreserverapi.TransactionTypeAutoRenewableSubscription
+	}
+
+	switch {
+	case i.IsTrialPeriod == "true":
+		out.OfferType = appstoreserverapi.OfferTypeIntroductory
+		out.OfferDiscountType = appstoreserverapi.OfferDiscountTypeFreeTrial
+	case i.IsInIntroOfferPeriod == "true":
+		out.OfferType = appstoreserverapi.OfferTypeIntroductory
+	case i.PromotionalOfferID != "":
+		out.OfferType = appstoreserverapi.OfferTypePromotional
+		out.OfferIdentifier = i.PromotionalOfferID
+	case i.OfferCodeRefName != "":
+		out.OfferType = appstoreserverapi.OfferTypeSubscription
+		out.OfferIdentifier = i.OfferCodeRefName
+	}
+
+	return out
+}
+
+// PendingRenewalInfo An array of elements that refers to open or failed auto-renewable subscription renewals
+// https://developer.apple.com/documentation/appstorereceipts/responsebody/pending_renewal_info
+type PendingRenewalInfo struct {
+	// The current renewal preference for the auto-renewable subscription
+	AutoRenewProductID string `json:"auto_renew_product_id"`
+	// The current renewal status for the auto-renewable subscription: "1" will renew, "0" turned off
+	AutoRenewStatus string `json:"auto_renew_status"`
+	// The reason a subscription expired
+	ExpirationIntent string `json:"expiration_intent"`
+	// The time at which the grace period for subscription renewals expires, UNIX time in milliseconds
+	GracePeriodExpiresDateMS string `json:"grace_period_expires_date_ms"`
+	// A flag that indicates Apple is attempting to renew an expired subscription automatically: "1" or "0"
+	IsInBillingRetryPeriod string `json:"is_in_billing_retry_period"`
+	// The reference name of a subscription offer code that the customer redeemed
+	OfferCodeRefName string `json:"offer_code_ref_name"`
+	// The transaction identifier of the original purchase
+	OriginalTransactionID string `json:"original_transaction_id"`
+	// The price consent status for a subscription price increase: "1" consented, "0" not yet responded
+	PriceConsentStatus string `json:"price_consent_status"`
+	// The unique identifier of the product purchased
+	ProductID string `json:"product_id"`
+	// The identifier of the promotional offer for an auto-renewable subscription that the user redeemed
+	PromotionalOfferID string `json:"promotional_offer_id"`
+}
+
+// RenewalInfo 转换为 appstoreserverapi.RenewalInfo
+func (p *PendingRenewalInfo) RenewalInfo(environment appstoreserverapi.Environment) appstoreserverapi.RenewalInfo {
+	out := appstoreserverapi.RenewalInfo{
+		AutoRenewProductID:     p.AutoRenewProductID,
+		AutoRenewStatus:        appstoreserverapi.AutoRenewStatus(parseInt(p.AutoRenewStatus)),
+		Environment:            environment,
+		ExpirationIntent:       appstoreserverapi.ExpirationIntent(parseInt(p.ExpirationIntent)),
+		GracePeriodExpiresDate: parseInt(p.GracePeriodExpiresDateMS),
+		IsInBillingRetryPeriod: p.IsInBillingRetryPeriod == "1",
+		OriginalTransactionID:  p.OriginalTransactionID,
+		ProductID:              p.ProductID,
+	}
+
+	if p.PriceConsentStatus != "" {
+		status := int32(parseInt(p.PriceConsentStatus))
+		out.PriceIncreaseStatus = &status
+	}
+
+	switch {
+	case p.PromotionalOfferID != "":
+		out.OfferType = appstoreserverapi.OfferTypePromotional
+		out.OfferIdentifier = p.PromotionalOfferID
+	case p.OfferCodeRefName != "":
+		out.OfferType = appstoreserverapi.OfferTypeSubscription
+		out.OfferIdentifier = p.OfferCodeRefName
+	}
+
+	return out
+}
+
+// parseInt 解析收据中字符串形式的数字，为空或格式错误时返回 0
+func parseInt(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
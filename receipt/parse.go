@@ -0,0 +1,280 @@
+package receipt
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/beanscc/appstore/internal/receiptasn1"
+	"github.com/beanscc/appstore/pkcs7"
+)
+
+// AppleIncRootCertificate.cer download from Apple PKI: https://www.apple.com/certificateauthority/
+// openssl x509 -in AppleIncRootCertificate.cer -inform DER -out AppleIncRootCertificate.pem -outform PEM
+const appleRootCertificate = `
+-----BEGIN CERTIFICATE-----
+MIIEuzCCA6OgAwIBAgIBAjANBgkqhkiG9w0BAQUFADBiMQswCQYDVQQGEwJVUzET
+MBEGA1UEChMKQXBwbGUgSW5jLjEmMCQGA1UECxMdQXBwbGUgQ2VydGlmaWNhdGlv
+biBBdXRob3JpdHkxFjAUBgNVBAMTDUFwcGxlIFJvb3QgQ0EwHhcNMDYwNDI1MjE0
+MDM2WhcNMzUwMjA5MjE0MDM2WjBiMQswCQYDVQQGEwJVUzETMBEGA1UEChMKQXBw
+bGUgSW5jLjEmMCQGA1UECxMdQXBwbGUgQ2VydGlmaWNhdGlvbiBBdXRob3JpdHkx
+FjAUBgNVBAMTDUFwcGxlIFJvb3QgQ0EwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAw
+ggEKAoIBAQDkkakJH5HbHkdQ6wXtXnmELes2oldMVeyLGYne+Uts9QerIjAC6Bg+
++FAJ039BqJj50cpmnCRrEdCju+QbKsMflZ56DKRHi1vUFjczy8QPTc4UadHJGXL1
+XQ7Vf1+b8iUDulWPTV0N8WQ1IxVLFVkds5T39pyez1C6wVhQZ48ItCD3y6wsIG9w
+tj8BMIy3Q88PnT3zK0koGsj+zrW5DtleHNbLPbU6rfQPDgCSC7EhFi501TwN22IW
+q6NxkkdTVcGvL0Gz+PvjcM3mo0xFfh9Ma1CWQYnEdGILEINBhzOKgbEwWOxaBDKM
+aLOPHd5lc/9nXmW8Sdh2nzMUZaF3lMktAgMBAAGjggF6MIIBdjAOBgNVHQ8BAf8E
+BAMCAQYwDwYDVR0TAQH/BAUwAwEB/zAdBgNVHQ4EFgQUK9BpR5R2Cf70a40uQKb3
+R01/CF4wHwYDVR0jBBgwFoAUK9BpR5R2Cf70a40uQKb3R01/CF4wggERBgNVHSAE
+ggEIMIIBBDCCAQAGCSqGSIb3Y2QFATCB8jAqBggrBgEFBQcCARYeaHR0cHM6Ly93
+d3cuYXBwbGUuY29tL2FwcGxlY2EvMIHDBggrBgEFBQcCAjCBthqBs1JlbGlhbmNl
+IG9uIHRoaXMgY2VydGlmaWNhdGUgYnkgYW55IHBhcnR5IGFzc3VtZXMgYWNjZXB0
+YW5jZSBvZiB0aGUgdGhlbiBhcHBsaWNhYmxlIHN0YW5kYXJkIHRlcm1zIGFuZCBj
+b25kaXRpb25zIG9mIHVzZSwgY2VydGlmaWNhdGUgcG9saWN5IGFuZCBjZXJ0aWZp
+Y2F0aW9uIHByYWN0aWNlIHN0YXRlbWVudHMuMA0GCSqGSIb3DQEBBQUAA4IBAQBc
+NplMLXi37Yyb3PN3m/J20ncwT8EfhYOFG5k9RzfyqZtAjizUsZAS2L70c5vu0mQP
+y3lPNNiiPvl4/2vIB+x9OYOLUyDTOMSxv5pPCmv/K/xZpwUJfBdAVhEedNO3iyM7
+R6PVbyTi69G3cN8PReEnyvFteO3ntRcXqNx+IjXKJdXZD9Zr1KIkIxH3oayPc4Fg
+xhtbCS+SsvhESPBgOJ4V9T0mZyCKM2r3DYLP3uujL/lTaltkwGMzd/c6ByxW69oP
+IQ7aunMZT7XZNn/Bh1XZp5m5MkL72NVxnn6hUrcbvZNCJBIqxw8dtk2cXmPIS4AX
+UKqK1drk/NAJBzewdXUh
+-----END CERTIFICATE-----
+`
+
+// appleRoots 内置的 Apple Inc. Root Certificate
+var appleRoots = func() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if ok := pool.AppendCertsFromPEM([]byte(appleRootCertificate)); !ok {
+		panic("appstore.receipt: failed to append apple root certificate")
+	}
+	return pool
+}()
+
+type parseOptions struct {
+	roots            *x509.CertPool
+	verificationTime time.Time
+}
+
+// ParseOption Parse 可选配置
+type ParseOption func(o *parseOptions)
+
+// WithRoots 设置验证收据签名证书链的可信根证书，默认为内置的 Apple Inc. Root Certificate
+func WithRoots(roots *x509.CertPool) ParseOption {
+	return func(o *parseOptions) {
+		o.roots = roots
+	}
+}
+
+// WithVerificationTime 设置验证证书有效期的时间，默认为收据的创建时间（字段 12），
+// 收据不包含创建时间时为当前时间
+func WithVerificationTime(t time.Time) ParseOption {
+	return func(o *parseOptions) {
+		o.verificationTime = t
+	}
+}
+
+// Parse 解析 PKCS#7 格式的收据（base64 解码后的内容），验证签名及证书链后解析收据内容
+// 日期字段转换为 UNIX 毫秒时间的字符串，与 verifyReceipt 的响应一致
+func Parse(data []byte, opts ...ParseOption) (*Receipt, error) {
+	o := parseOptions{roots: appleRoots}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sd, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	at := o.verificationTime
+	if at.IsZero() {
+		if at, err = receiptCreationTime(sd.Content, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sd.Verify(pkcs7.WithRoots(o.roots), pkcs7.WithVerificationTime(at)); err != nil {
+		return nil, err
+	}
+
+	return parsePayload(sd.Content)
+}
+
+// receiptCreationTime 返回验证证书链使用的收据创建时间，证书链须在该时间有效，
+// 签名证书过期后仍可验证其有效期内创建的收据；不包含创建时间时返回 now，晚于 now 时返回 error
+func receiptCreationTime(payload []byte, now time.Time) (time.Time, error) {
+	attrs, err := parseAttributes(payload)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, attr := range attrs {
+		if attr.Type != receiptasn1.FieldReceiptCreationDate {
+			continue
+		}
+
+		t, err := parseTime(attr.Value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("appstore.receipt: parse field %d: %w", attr.Type, err)
+		}
+		if t.IsZero() {
+			break
+		}
+		if t.After(now) {
+			return time.Time{}, fmt.Errorf("appstore.receipt: receipt creation date %s is in the future", t.Format(time.RFC3339))
+		}
+		return t, nil
+	}
+
+	return now, nil
+}
+
+// ParseUnverified 解析 PKCS#7 格式的收据，不验证签名
+// 仅用于从收据中获取交易 ID 等不依赖收据真实性的场景
+func ParseUnverified(data []byte) (*Receipt, error) {
+	sd, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePayload(sd.Content)
+}
+
+func parsePayload(payload []byte) (*Receipt, error) {
+	attrs, err := parseAttributes(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var out Receipt
+	for _, attr := range attrs {
+		switch attr.Type {
+		case receiptasn1.FieldBundleID:
+			err = parseString(attr.Value, &out.BundleID)
+		case receiptasn1.FieldApplicationVersion:
+			err = parseString(attr.Value, &out.ApplicationVersion)
+		case receiptasn1.FieldOriginalApplicationVersion:
+			err = parseString(attr.Value, &out.OriginalApplicationVersion)
+		case receiptasn1.FieldReceiptCreationDate:
+			err = parseDate(attr.Value, &out.ReceiptCreationDateMS)
+		case receiptasn1.FieldExpirationDate:
+			err = parseDate(attr.Value, &out.ExpirationDateMS)
+		case receiptasn1.FieldInApp:
+			var inApp *InApp
+			if inApp, err = parseInApp(attr.Value); err == nil {
+				out.InApp = append(out.InApp, *inApp)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("appstore.receipt: parse field %d: %w", attr.Type, err)
+		}
+	}
+
+	return &out, nil
+}
+
+func parseInApp(payload []byte) (*InApp, error) {
+	attrs, err := parseAttributes(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var out InApp
+	for _, attr := range attrs {
+		switch attr.Type {
+		case receiptasn1.FieldQuantity:
+			err = parseInteger(attr.Value, &out.Quantity)
+		case receiptasn1.FieldProductID:
+			err = parseString(attr.Value, &out.ProductID)
+		case receiptasn1.FieldTransactionID:
+			err = parseString(attr.Value, &out.TransactionID)
+		case receiptasn1.FieldOriginalTransactionID:
+			err = parseString(attr.Value, &out.OriginalTransactionID)
+		case receiptasn1.FieldPurchaseDate:
+			err = parseDate(attr.Value, &out.PurchaseDateMS)
+		case receiptasn1.FieldOriginalPurchaseDate:
+			err = parseDate(attr.Value, &out.OriginalPurchaseDateMS)
+		case receiptasn1.FieldExpiresDate:
+			err = parseDate(attr.Value, &out.ExpiresDateMS)
+		case receiptasn1.FieldCancellationDate:
+			err = parseDate(attr.Value, &out.CancellationDateMS)
+		case receiptasn1.FieldWebOrderLineItemID:
+			err = parseInteger(attr.Value, &out.WebOrderLineItemID)
+		case receiptasn1.FieldIsTrialPeriod:
+			err = parseBool(attr.Value, &out.IsTrialPeriod)
+		case receiptasn1.FieldIsInIntroOfferPeriod:
+			err = parseBool(attr.Value, &out.IsInIntroOfferPeriod)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("in-app field %d: %w", attr.Type, err)
+		}
+	}
+
+	return &out, nil
+}
+
+// parseAttributes 解析 Payload ::= SET OF ReceiptAttribute
+func parseAttributes(payload []byte) ([]receiptasn1.Attribute, error) {
+	attrs, err := receiptasn1.ParseAttributes(payload)
+	if err != nil {
+		return nil, fmt.Errorf("appstore.receipt: %w", err)
+	}
+
+	return attrs, nil
+}
+
+// parseString 解析 UTF8String 或 IA5String
+func parseString(value []byte, out *string) error {
+	_, err := asn1.Unmarshal(value, out)
+	return err
+}
+
+func parseInteger(value []byte, out *string) error {
+	var v int64
+	if _, err := asn1.Unmarshal(value, &v); err != nil {
+		return err
+	}
+
+	*out = strconv.FormatInt(v, 10)
+	return nil
+}
+
+func parseBool(value []byte, out *string) error {
+	var v int64
+	if _, err := asn1.Unmarshal(value, &v); err != nil {
+		return err
+	}
+
+	*out = strconv.FormatBool(v != 0)
+	return nil
+}
+
+// parseDate 解析 RFC 3339 格式的 IA5String 日期，转换为 UNIX 毫秒时间，空字符串表示没有该日期
+func parseDate(value []byte, out *string) error {
+	t, err := parseTime(value)
+	if err != nil || t.IsZero() {
+		return err
+	}
+
+	*out = strconv.FormatInt(t.UnixMilli(), 10)
+	return nil
+}
+
+// parseTime 解析 RFC 3339 格式的日期，空字符串返回零值
+func parseTime(value []byte) (time.Time, error) {
+	var s string
+	if err := parseString(value, &s); err != nil {
+		return time.Time{}, err
+	}
+
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
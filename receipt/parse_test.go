@@ -0,0 +1,195 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beanscc/appstore/internal/receiptasn1"
+	"github.com/beanscc/appstore/pkcs7/pkcs7test"
+)
+
+// testAttributes 将 field -> value 编码为 SET OF ReceiptAttribute，value 为 string 时按 UTF8String 编码
+func testAttributes(t *testing.T, fields map[int]interface{}) []byte {
+	var attrs []receiptasn1.Attribute
+	for typ, v := range fields {
+		var (
+			value []byte
+			err   error
+		)
+		switch v := v.(type) {
+		case string:
+			value, err = asn1.MarshalWithParams(v, "utf8")
+		case time.Time:
+			value, err = asn1.MarshalWithParams(v.UTC().Format(time.RFC3339), "ia5")
+		case []byte:
+			value = v
+		default:
+			value, err = asn1.Marshal(v)
+		}
+		if err != nil {
+			t.Fatalf("asn1.Marshal failed. err:%v", err)
+		}
+		attrs = append(attrs, receiptasn1.Attribute{Type: typ, Version: 1, Value: value})
+	}
+
+	out, err := asn1.MarshalWithParams(attrs, "set")
+	if err != nil {
+		t.Fatalf("asn1.Marshal failed. err:%v", err)
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	signer, err := pkcs7test.NewSigner()
+	if err != nil {
+		t.Fatalf("pkcs7test.NewSigner failed. err:%v", err)
+	}
+
+	created := time.Now().Add(-time.Minute).Truncate(time.Second)
+	purchased := created.Add(-24 * time.Hour)
+	inApp := testAttributes(t, map[int]interface{}{
+		receiptasn1.FieldQuantity:              1,
+		receiptasn1.FieldProductID:             "com.example.monthly",
+		receiptasn1.FieldTransactionID:         "1001",
+		receiptasn1.FieldOriginalTransactionID: "1000",
+		receiptasn1.FieldPurchaseDate:          purchased,
+		receiptasn1.FieldOriginalPurchaseDate:  purchased,
+		receiptasn1.FieldExpiresDate:           purchased.AddDate(0, 1, 0),
+		receiptasn1.FieldCancellationDate:      "",
+		receiptasn1.FieldWebOrderLineItemID:    2000,
+		receiptasn1.FieldIsTrialPeriod:         1,
+		receiptasn1.FieldIsInIntroOfferPeriod:  0,
+	})
+	payload := testAttributes(t, map[int]interface{}{
+		receiptasn1.FieldBundleID:                   "com.example",
+		receiptasn1.FieldApplicationVersion:         "12",
+		receiptasn1.FieldOriginalApplicationVersion: "1.0",
+		receiptasn1.FieldReceiptCreationDate:        created,
+		receiptasn1.FieldInApp:                      inApp,
+		4:                                           []byte{0x04, 0x02, 0x01, 0x02},
+	})
+
+	data, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+
+	got, err := Parse(data, WithRoots(signer.Roots()))
+	if err != nil {
+		t.Errorf("TestParse failed. err:%v", err)
+		return
+	}
+
+	if got.BundleID != "com.example" || got.ApplicationVersion != "12" || got.OriginalApplicationVersion != "1.0" ||
+		got.ReceiptCreationDateMS != formatMS(created) || len(got.InApp) != 1 {
+		t.Errorf("TestParse got receipt:%#v", got)
+		return
+	}
+
+	want := InApp{
+		Quantity:               "1",
+		ProductID:              "com.example.monthly",
+		TransactionID:          "1001",
+		OriginalTransactionID:  "1000",
+		PurchaseDateMS:         formatMS(purchased),
+		OriginalPurchaseDateMS: formatMS(purchased),
+		ExpiresDateMS:          formatMS(purchased.AddDate(0, 1, 0)),
+		WebOrderLineItemID:     "2000",
+		IsTrialPeriod:          "true",
+		IsInIntroOfferPeriod:   "false",
+	}
+	if got.InApp[0] != want {
+		t.Errorf("TestParse got in_app:%#v, want:%#v", got.InApp[0], want)
+	}
+
+	// 默认使用内置的 Apple Inc. Root Certificate，不信任测试证书
+	var unknown x509.UnknownAuthorityError
+	if _, err := Parse(data); !errors.As(err, &unknown) {
+		t.Errorf("TestParse got err:%v, want x509.UnknownAuthorityError", err)
+	}
+
+	if _, err := Parse(data, WithRoots(signer.Roots()), WithVerificationTime(time.Now().Add(2*time.Hour))); err == nil {
+		t.Errorf("TestParse: expired verification time should be rejected")
+	}
+
+	// 签名证书过期后，默认按收据的创建时间验证，创建时间须在证书有效期内
+	expired, err := pkcs7test.NewSigner(pkcs7test.WithValidity(time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour)))
+	if err != nil {
+		t.Fatalf("pkcs7test.NewSigner failed. err:%v", err)
+	}
+	if data, err = expired.Sign(payload); err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+	if _, err := Parse(data, WithRoots(expired.Roots())); err == nil {
+		t.Errorf("TestParse: receipt created after signer expired should be rejected")
+	}
+
+	signedInValidity := testAttributes(t, map[int]interface{}{
+		receiptasn1.FieldBundleID:            "com.example",
+		receiptasn1.FieldReceiptCreationDate: time.Now().Add(-36 * time.Hour),
+		receiptasn1.FieldInApp:               inApp,
+	})
+	if data, err = expired.Sign(signedInValidity); err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+	if _, err := Parse(data, WithRoots(expired.Roots())); err != nil {
+		t.Errorf("TestParse expired signer failed. err:%v", err)
+	}
+
+	if _, err := Parse(data, WithRoots(signer.Roots())); err == nil {
+		t.Errorf("TestParse: untrusted signer should be rejected")
+	}
+
+	if unverified, err := ParseUnverified(data); err != nil || unverified.InApp[0].TransactionID != "1001" {
+		t.Errorf("TestParse ParseUnverified got:%#v, err:%v", unverified, err)
+	}
+}
+
+func TestParse_futureCreationDate(t *testing.T) {
+	signer, err := pkcs7test.NewSigner()
+	if err != nil {
+		t.Fatalf("pkcs7test.NewSigner failed. err:%v", err)
+	}
+
+	data, err := signer.Sign(testAttributes(t, map[int]interface{}{
+		receiptasn1.FieldBundleID:            "com.example",
+		receiptasn1.FieldReceiptCreationDate: time.Now().Add(time.Hour),
+	}))
+	if err != nil {
+		t.Fatalf("signer.Sign failed. err:%v", err)
+	}
+
+	if _, err := Parse(data, WithRoots(signer.Roots())); err == nil {
+		t.Errorf("TestParse_futureCreationDate: future creation date should be rejected")
+	}
+}
+
+func TestAppleRoot(t *testing.T) {
+	block, _ := pem.Decode([]byte(appleRootCertificate))
+	if block == nil {
+		t.Fatalf("TestAppleRoot: invalid pem")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("TestAppleRoot failed. err:%v", err)
+	}
+
+	// https://www.apple.com/certificateauthority/ 公布的 Apple Inc. Root 证书 SHA-256 指纹
+	sum := sha256.Sum256(cert.Raw)
+	if got := strings.ToUpper(hex.EncodeToString(sum[:])); got != "B0B1730ECBC7FF4505142C49F1295E6EDA6BCAED7E2C68C5BE91B5A11001F024" {
+		t.Errorf("TestAppleRoot got fingerprint:%s", got)
+	}
+}
+
+func formatMS(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
@@ -0,0 +1,203 @@
+package receipt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// verifyReceipt 地址
+// https://developer.apple.com/documentation/appstorereceipts/verifyreceipt
+const (
+	ProductionURL = "https://buy.itunes.apple.com/verifyReceipt"
+	SandboxURL    = "https://sandbox.itunes.apple.com/verifyReceipt"
+)
+
+// Status verifyReceipt 响应的 status
+// https://developer.apple.com/documentation/appstorereceipts/status
+type Status int
+
+const (
+	StatusOK Status = 0
+	// The request to the App Store was not made using the HTTP POST request method
+	StatusBadMethod Status = 21000
+	// The data in the receipt-data property was malformed or the service experienced a temporary issue
+	StatusMalformedData Status = 21002
+	// The receipt could not be authenticated
+	StatusNotAuthenticated Status = 21003
+	// The shared secret you provided does not match the shared secret on file for your account
+	StatusSharedSecretMismatch Status = 21004
+	// The receipt server was temporarily unable to provide the receipt
+	StatusServerUnavailable Status = 21005
+	// This receipt is valid but the subscription has expired
+	StatusSubscriptionExpired Status = 21006
+	// This receipt is from the test environment, but it was sent to the production environment for verification
+	StatusSandboxReceipt Status = 21007
+	// This receipt is from the production environment, but it was sent to the test environment for verification
+	StatusProductionReceipt Status = 21008
+	// Internal data access error
+	StatusInternalDataAccess Status = 21009
+	// The user account cannot be found or has been deleted
+	StatusAccountNotFound Status = 21010
+)
+
+// StatusError verifyReceipt 返回非 0 的 status
+type StatusError struct {
+	Status Status
+	// Apple 建议稍后重试，status 为 21100-21199 时由响应的 is-retryable 指示
+	IsRetryable bool
+}
+
+func (e *StatusError) Error() string {
+	return "appstore.receipt: verifyReceipt status:" + strconv.Itoa(int(e.Status)) +
+		", retryable:" + strconv.FormatBool(e.IsRetryable)
+}
+
+// Client verifyReceipt 客户端
+// Apple 已弃用 verifyReceipt，建议从收据中获取交易 ID 后迁移到 App Store Server API
+type Client struct {
+	client *http.Client
+	// App 专用共享密钥，收据包含自动续期订阅时必填
+	password string
+	// 是否优先请求 sandbox 环境
+	sandbox bool
+	// http request timeout
+	timeout time.Duration
+	// verifyReceipt 地址，为空时使用 ProductionURL、SandboxURL
+	productionURL string
+	sandboxURL    string
+}
+
+// NewClient password 为 App Store Connect 中的 App 专用共享密钥
+func NewClient(password string) *Client {
+	return &Client{
+		client:   http.DefaultClient,
+		password: password,
+	}
+}
+
+func (c *Client) clone() *Client {
+	nc := new(Client)
+	*nc = *c
+
+	return nc
+}
+
+// Sandbox 优先请求 sandbox 环境，返回 21008 时再请求 production 环境
+func (c *Client) Sandbox(sandbox bool) *Client {
+	nc := c.clone()
+	nc.sandbox = sandbox
+	return nc
+}
+
+// URL 设置 production 及 sandbox 环境的 verifyReceipt 地址，如代理或本地 mock 服务的地址
+func (c *Client) URL(production string, sandbox string) *Client {
+	nc := c.clone()
+	nc.productionURL, nc.sandboxURL = production, sandbox
+	return nc
+}
+
+// Timeout 设置单次请求的超时时间，默认 10s
+func (c *Client) Timeout(timeout time.Duration) *Client {
+	nc := c.clone()
+	nc.timeout = timeout
+	return nc
+}
+
+// request verifyReceipt 请求 body
+// https://developer.apple.com/documentation/appstorereceipts/requestbody
+type request struct {
+	ReceiptData            string `json:"receipt-data"`
+	Password               string `json:"password,omitempty"`
+	ExcludeOldTransactions bool   `json:"exclude-old-transactions,omitempty"`
+}
+
+// Verify 验证 base64 编码的收据
+//   - 默认先请求 production 环境，返回 21007 时自动请求 sandbox 环境；Sandbox(true) 时相反
+//   - excludeOldTransactions 为 true 时，latest_receipt_info 只包含每个自动续期订阅的最新交易
+//   - status 非 0 时返回 *StatusError 及解析后的响应，如 21006 (订阅已过期) 时响应仍包含 receipt 及 latest_receipt_info
+func (c *Client) Verify(ctx context.Context, receiptData string, excludeOldTransactions bool) (*Response, error) {
+	if receiptData == "" {
+		return nil, errors.New("appstore.receipt: empty receipt data")
+	}
+
+	req := &request{ReceiptData: receiptData, Password: c.password, ExcludeOldTransactions: excludeOldTransactions}
+
+	productionURL, sandboxURL := ProductionURL, SandboxURL
+	if c.productionURL != "" {
+		productionURL = c.productionURL
+	}
+	if c.sandboxURL != "" {
+		sandboxURL = c.sandboxURL
+	}
+
+	url, retryURL, retryStatus := productionURL, sandboxURL, StatusSandboxReceipt
+	if c.sandbox {
+		url, retryURL, retryStatus = sandboxURL, productionURL, StatusProductionReceipt
+	}
+
+	resp, err := c.post(ctx, url, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == retryStatus {
+		if resp, err = c.post(ctx, retryURL, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.Status != StatusOK {
+		return resp, &StatusError{Status: resp.Status, IsRetryable: resp.IsRetryable}
+	}
+
+	return resp, nil
+}
+
+func (c *Client) post(ctx context.Context, url string, in *request) (*Response, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("appstore.receipt: verifyReceipt http status:%d", resp.StatusCode)
+	}
+
+	var out Response
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
@@ -0,0 +1,132 @@
+package receipt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/beanscc/appstore/appstoreserverapi"
+)
+
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return NewClient("secret").URL(srv.URL+"/production/verifyReceipt", srv.URL+"/sandbox/verifyReceipt")
+}
+
+const testSandboxResponse = `{
+  "status": 0,
+  "environment": "Sandbox",
+  "receipt": {
+    "bundle_id": "com.example",
+    "application_version": "12",
+    "in_app": [
+      {"quantity": "1", "product_id": "com.example.monthly", "transaction_id": "1000", "original_transaction_id": "1000",
+       "purchase_date_ms": "1698148900000", "expires_date_ms": "1698149200000", "is_trial_period": "true"}
+    ]
+  },
+  "latest_receipt_info": [
+    {"quantity": "1", "product_id": "com.example.monthly", "transaction_id": "1001", "original_transaction_id": "1000",
+     "purchase_date_ms": "1698149200000", "expires_date_ms": "1698149500000", "is_trial_period": "false",
+     "cancellation_date_ms": "1698149300000", "cancellation_reason": "1", "in_app_ownership_type": "PURCHASED",
+     "promotional_offer_id": "winback", "web_order_line_item_id": "2000"}
+  ],
+  "pending_renewal_info": [
+    {"auto_renew_product_id": "com.example.yearly", "auto_renew_status": "1", "original_transaction_id": "1000",
+     "product_id": "com.example.monthly", "is_in_billing_retry_period": "0", "price_consent_status": "1"}
+  ]
+}`
+
+func TestClient_Verify(t *testing.T) {
+	var hosts []string
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ReceiptData != "MIIT" || req.Password != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		hosts = append(hosts, r.URL.Path)
+		switch r.URL.Path {
+		case "/production/verifyReceipt":
+			w.Write([]byte(`{"status":21007}`))
+		case "/sandbox/verifyReceipt":
+			w.Write([]byte(testSandboxResponse))
+		}
+	})
+
+	resp, err := c.Verify(context.Background(), "MIIT", false)
+	if err != nil {
+		t.Errorf("TestClient_Verify failed. err:%v", err)
+		return
+	}
+
+	if len(hosts) != 2 || resp.Environment != appstoreserverapi.EnvironmentSandbox {
+		t.Errorf("TestClient_Verify got hosts:%v, environment:%s", hosts, resp.Environment)
+	}
+
+	transactions := resp.Transactions()
+	if len(transactions) != 1 {
+		t.Errorf("TestClient_Verify got transactions:%#v", transactions)
+		return
+	}
+
+	tx := transactions[0]
+	if tx.TransactionID != "1001" || tx.BundleID != "com.example" || tx.Environment != appstoreserverapi.EnvironmentSandbox ||
+		tx.ExpiresDate != 1698149500000 || tx.RevocationDate != 1698149300000 || tx.RevocationReason == nil || *tx.RevocationReason != 1 ||
+		tx.Type != appstoreserverapi.TransactionTypeAutoRenewableSubscription ||
+		tx.OfferType != appstoreserverapi.OfferTypePromotional || tx.OfferIdentifier != "winback" || tx.Quantity != 1 {
+		t.Errorf("TestClient_Verify got transaction:%#v", tx)
+	}
+
+	inApp := resp.Receipt.InApp[0].Transaction(resp.Receipt.BundleID, resp.Environment)
+	if inApp.OfferType != appstoreserverapi.OfferTypeIntroductory || inApp.OfferDiscountType != appstoreserverapi.OfferDiscountTypeFreeTrial {
+		t.Errorf("TestClient_Verify got in_app transaction:%#v", inApp)
+	}
+
+	renewals := resp.RenewalInfos()
+	if len(renewals) != 1 || renewals[0].AutoRenewStatus != appstoreserverapi.AutoRenewStatusOn ||
+		renewals[0].AutoRenewProductID != "com.example.yearly" || renewals[0].PriceIncreaseStatus == nil {
+		t.Errorf("TestClient_Verify got renewal infos:%#v", renewals)
+	}
+}
+
+func TestClient_Verify_status(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sandbox/verifyReceipt":
+			w.Write([]byte(`{"status":21008}`))
+		case "/production/verifyReceipt":
+			w.Write([]byte(`{"status":21100,"is-retryable":true}`))
+		}
+	}).Sandbox(true)
+
+	_, err := c.Verify(context.Background(), "MIIT", true)
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.Status != 21100 || !serr.IsRetryable {
+		t.Errorf("TestClient_Verify_status got err:%v", err)
+	}
+}
+
+func TestClient_Verify_expired(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Replace(testSandboxResponse, `"status": 0`, `"status": 21006`, 1)))
+	})
+
+	resp, err := c.Verify(context.Background(), "MIIT", false)
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.Status != StatusSubscriptionExpired {
+		t.Errorf("TestClient_Verify_expired got err:%v", err)
+		return
+	}
+
+	if resp == nil || resp.Status != StatusSubscriptionExpired || len(resp.Transactions()) != 1 {
+		t.Errorf("TestClient_Verify_expired got resp:%#v", resp)
+	}
+}